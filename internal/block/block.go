@@ -1,6 +1,7 @@
 package block
 
 import (
+	"encoding/binary"
 	"fmt"
 	"strings"
 
@@ -13,6 +14,8 @@ type Block struct {
 	data    []byte
 	offsets []uint16
 	size    int
+
+	hashIndex *HashIndex // nil when the block was encoded without one
 }
 
 func (b *Block) Size() int {
@@ -59,6 +62,26 @@ func (b *Block) Entries() []Entry {
 	return entries
 }
 
+// Len returns the number of entries of the block
+func (b *Block) Len() int {
+	return len(b.offsets)
+}
+
+// KeyAt returns the key of the entry at idx without copying it
+func (b *Block) KeyAt(idx int) types.Bytes {
+	off := int(b.offsets[idx])
+	keyLen := int(binary.BigEndian.Uint16(b.data[off:]))
+	return b.data[off+2 : off+2+keyLen]
+}
+
+// ValueAt returns the value of the entry at idx without copying it
+func (b *Block) ValueAt(idx int) types.Bytes {
+	off := int(b.offsets[idx])
+	off += 2 + int(binary.BigEndian.Uint16(b.data[off:]))
+	valueLen := int(binary.BigEndian.Uint16(b.data[off:]))
+	return b.data[off+2 : off+2+valueLen]
+}
+
 func (b *Block) First() (Entry, error) {
 	e, err := b.first()
 	if err != nil {
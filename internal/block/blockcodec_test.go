@@ -1,6 +1,7 @@
 package block
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -41,3 +42,34 @@ func TestBlockDecodeCorruptData(t *testing.T) {
 	_, err = Decode(corrupt)
 	assert.Error(t, err)
 }
+
+func TestBlockHashIndexGet(t *testing.T) {
+	b := NewBuilder(WithBlockSize(4096))
+	for i := range 100 {
+		b.Add(types.Bytes(fmt.Sprintf("key%03d", i)), types.Bytes(fmt.Sprintf("val%03d", i)))
+	}
+	blk := b.Build()
+
+	encoded, err := EncodeWithHashIndex(&blk)
+	assert.NoError(t, err)
+
+	decoded, err := DecodeWithHashIndex(encoded)
+	assert.NoError(t, err)
+	assert.NotNil(t, decoded.hashIndex)
+	assert.Equal(t, blk.offsets, decoded.offsets)
+
+	for _, b := range []*Block{&blk, decoded} {
+		for i := range 100 {
+			val, found, err := b.Get(types.Bytes(fmt.Sprintf("key%03d", i)))
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, types.Bytes(fmt.Sprintf("val%03d", i)), val)
+		}
+
+		for _, k := range []string{"key", "key100", "key05", "a", "z"} {
+			_, found, err := b.Get(types.Bytes(k))
+			assert.NoError(t, err)
+			assert.False(t, found)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package block
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+)
+
+const (
+	hashIndexEmpty     uint16 = 0xFFFF // no key of the block hashes to the bucket
+	hashIndexCollision uint16 = 0xFFFE // several keys do, binary search instead
+)
+
+// HashIndex maps the hash of a key to the position of its entry in the block,
+// so point lookups do not binary search the block
+type HashIndex struct {
+	buckets []uint16
+}
+
+// NewHashIndex indexes every entry of blk, with buckets filled at 75% at most
+func NewHashIndex(blk *Block) *HashIndex {
+	n := len(blk.offsets)*4/3 + 1
+	if n > 0xFFFF {
+		n = 0xFFFF
+	}
+
+	h := &HashIndex{buckets: make([]uint16, n)}
+	for i := range h.buckets {
+		h.buckets[i] = hashIndexEmpty
+	}
+
+	for idx := range blk.offsets {
+		b := h.bucket(blk.KeyAt(idx))
+		switch {
+		case h.buckets[b] == hashIndexEmpty && idx < int(hashIndexCollision):
+			h.buckets[b] = uint16(idx)
+		default:
+			h.buckets[b] = hashIndexCollision
+		}
+	}
+
+	return h
+}
+
+// bucket hashes key with FNV-1a
+func (h *HashIndex) bucket(key []byte) int {
+	v := uint32(2166136261)
+	for _, c := range key {
+		v ^= uint32(c)
+		v *= 16777619
+	}
+	return int(v % uint32(len(h.buckets)))
+}
+
+// lookup returns the position of the only entry that may be key, -1 when
+// the block does not contain key and false when the index cannot tell
+func (h *HashIndex) lookup(key []byte) (int, bool) {
+	switch v := h.buckets[h.bucket(key)]; v {
+	case hashIndexEmpty:
+		return -1, true
+	case hashIndexCollision:
+		return 0, false
+	default:
+		return int(v), true
+	}
+}
+
+func (h *HashIndex) size() int {
+	return len(h.buckets)*2 + 2
+}
+
+// EncodeWithHashIndex encodes the block followed by a hash index of its entries
+//
+// +---------+---------------------+-----------------+
+// | block   |  buckets (2b each)  |  # buckets (2b) |
+// +---------+---------------------+-----------------+
+func EncodeWithHashIndex(b *Block) (types.Bytes, error) {
+	buf, err := encode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	h := NewHashIndex(b)
+	off := len(buf)
+	buf = append(buf, make([]byte, h.size())...)
+	for _, v := range h.buckets {
+		binary.BigEndian.PutUint16(buf[off:], v)
+		off += 2
+	}
+	binary.BigEndian.PutUint16(buf[off:], uint16(len(h.buckets)))
+
+	return types.Bytes(buf), nil
+}
+
+// DecodeWithHashIndex decodes a block encoded by EncodeWithHashIndex
+func DecodeWithHashIndex(data types.Bytes) (*Block, error) {
+	size := len(data)
+	if size < 2 {
+		return nil, fmt.Errorf("data too short to contain bucket count")
+	}
+
+	n := int(binary.BigEndian.Uint16(data[size-2:]))
+	if n == 0 || size < 2+n*2 {
+		return nil, fmt.Errorf("data too short for hash index: %d buckets, got %d bytes", n, size)
+	}
+
+	h := &HashIndex{buckets: make([]uint16, n)}
+	off := size - 2 - n*2
+	for i := range h.buckets {
+		h.buckets[i] = binary.BigEndian.Uint16(data[off+i*2:])
+	}
+
+	blk, err := decode(data[:size-2-n*2])
+	if err != nil {
+		return nil, err
+	}
+	blk.hashIndex = h
+	blk.size = size
+
+	return blk, nil
+}
+
+// Get returns the value of key, found through the hash index when the block has one
+func (b *Block) Get(key types.Bytes) (types.Bytes, bool, error) {
+	idx, ok := 0, false
+	if b.hashIndex != nil {
+		idx, ok = b.hashIndex.lookup(key)
+	}
+	if !ok {
+		idx = sort.Search(len(b.offsets), func(i int) bool {
+			return bytes.Compare(b.KeyAt(i), key) >= 0
+		})
+	}
+
+	if idx < 0 || idx >= len(b.offsets) || !bytes.Equal(b.KeyAt(idx), key) {
+		return nil, false, nil
+	}
+
+	e := entry{}
+	if err := e.decode(b.data[b.offsets[idx]:]); err != nil {
+		return nil, false, err
+	}
+	return e.value, true, nil
+}
+
+// HasHashIndex tells whether the block was decoded with a hash index
+func (b *Block) HasHashIndex() bool {
+	return b.hashIndex != nil
+}
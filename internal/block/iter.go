@@ -56,8 +56,25 @@ func (i *iter) Next() error {
 	return i.Seek(i.idx)
 }
 
+func (i *iter) Prev() error {
+	if !i.HasNext() {
+		return types.ErrIterEnd
+	}
+	if i.idx == 0 {
+		i.entr = nil
+		i.idx = -1
+		return types.ErrIterEnd
+	}
+
+	return i.Seek(i.idx - 1)
+}
+
+func (i *iter) SeekToLast() error {
+	return i.Seek(len(i.blk.offsets) - 1)
+}
+
 func (i *iter) Seek(idx int) error {
-	if idx >= len(i.blk.offsets) {
+	if idx < 0 || idx >= len(i.blk.offsets) {
 		i.entr = nil
 		return types.ErrIterEnd
 	}
@@ -117,3 +134,32 @@ func (i *iter) search(key types.Bytes) error {
 
 	return i.Seek(lowIdx)
 }
+
+// SeekForPrev binary searches the block to find the last <= key,
+// the iterator is on no entry when every key is after key
+func (i *iter) SeekForPrev(key types.Bytes) error {
+	lowIdx := 0
+	highIdx := len(i.blk.offsets)
+
+	// first entry > key
+	for lowIdx < highIdx {
+		midIdx := lowIdx + (highIdx-lowIdx)/2
+		if err := i.Seek(midIdx); err != nil {
+			return err
+		}
+
+		if bytes.Compare(i.entr.key, key) > 0 {
+			highIdx = midIdx
+		} else {
+			lowIdx = midIdx + 1
+		}
+	}
+
+	if lowIdx == 0 {
+		i.entr = nil
+		i.idx = -1
+		return nil
+	}
+
+	return i.Seek(lowIdx - 1)
+}
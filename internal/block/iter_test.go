@@ -117,3 +117,36 @@ func TestBlockIteratorSearch(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, types.Bytes("k099"), it.Key())
 }
+
+func TestBlockIteratorReverse(t *testing.T) {
+	b := NewBuilder(WithBlockSize(4096))
+	for i := range 50 {
+		b.Add(types.Bytes(fmt.Sprintf("k%03d", i*2)), types.Bytes(fmt.Sprintf("v%03d", i*2)))
+	}
+	blk := b.Build()
+	it := blk.Scan()
+
+	assert.NoError(t, it.SeekToLast())
+	var keys []string
+	for it.HasNext() {
+		keys = append(keys, string(it.Key()))
+		it.Prev()
+	}
+	assert.Len(t, keys, 50)
+	assert.Equal(t, "k098", keys[0])
+	assert.Equal(t, "k000", keys[49])
+	assert.ErrorIs(t, it.Prev(), types.ErrIterEnd)
+
+	assert.NoError(t, it.SeekForPrev(types.Bytes("k050")))
+	assert.Equal(t, "k050", string(it.Key()))
+	assert.NoError(t, it.SeekForPrev(types.Bytes("k051")))
+	assert.Equal(t, "k050", string(it.Key()))
+	assert.NoError(t, it.Prev())
+	assert.Equal(t, "k048", string(it.Key()))
+	assert.NoError(t, it.SeekForPrev(types.Bytes("z")))
+	assert.Equal(t, "k098", string(it.Key()))
+
+	// every key is after it
+	assert.NoError(t, it.SeekForPrev(types.Bytes("a")))
+	assert.False(t, it.HasNext())
+}
@@ -21,7 +21,8 @@ func Decode(b types.Bytes) (*Block, error) {
 }
 
 func encode(blk *Block) ([]byte, error) {
-	buf := make([]byte, blk.size)
+	// size also counts the hash index of a block decoded with one
+	buf := make([]byte, len(blk.data)+len(blk.offsets)*2+2)
 	copy(buf, blk.data)
 
 	pairCount := len(blk.offsets)
@@ -78,5 +79,5 @@ func decode(data []byte) (*Block, error) {
 		return nil, fmt.Errorf("data size mismatch: expected %d, got %d", dataEnd+len(offsetsBytes)+2, size)
 	}
 
-	return &Block{data: data[:dataEnd], offsets: offsets}, nil
+	return &Block{data: data[:dataEnd], offsets: offsets, size: size}, nil
 }
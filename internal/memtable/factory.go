@@ -0,0 +1,10 @@
+package memtable
+
+// Factory creates the memtables of a tree, New by default
+type Factory func(id int) MemTable
+
+// Freezer is implemented by memtables with work to do once they stop taking writes
+type Freezer interface {
+	// Freeze is called when the memtable becomes immutable, before it is read for a flush
+	Freeze()
+}
@@ -0,0 +1,160 @@
+package memtable
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+	"github.com/ttn-nguyen42/go-mini-lsm/pkg/arena"
+	"github.com/ttn-nguyen42/go-mini-lsm/pkg/skiplist"
+)
+
+// hashSkipListMemTable keeps a skip list per key prefix, a point lookup only
+// searches the keys sharing its prefix. Scans within a prefix read a single
+// skip list, other scans merge all of them
+type hashSkipListMemTable struct {
+	id        int
+	prefixLen int
+	lock      sync.RWMutex // guards buckets, the skip lists are safe for concurrent use
+	buckets   map[string]skiplist.SkipList[types.Bytes, types.Bytes]
+	arena     *arena.Arena
+	bytes     *arena.Allocator[byte]
+	logical   atomic.Int64
+}
+
+// NewHashSkipList returns a factory of memtables hashing keys by their first prefixLen bytes,
+// shorter keys are their own prefix
+func NewHashSkipList(prefixLen int) Factory {
+	return func(id int) MemTable {
+		a := arena.New(arenaSlabSize)
+
+		return &hashSkipListMemTable{
+			id:        id,
+			prefixLen: prefixLen,
+			buckets:   make(map[string]skiplist.SkipList[types.Bytes, types.Bytes]),
+			arena:     a,
+			bytes:     arena.NewAllocator[byte](a),
+		}
+	}
+}
+
+func (m *hashSkipListMemTable) Id() int {
+	return m.id
+}
+
+func (m *hashSkipListMemTable) prefix(key types.Bytes) types.Bytes {
+	return key[:min(m.prefixLen, len(key))]
+}
+
+func (m *hashSkipListMemTable) bucket(key types.Bytes) (skiplist.SkipList[types.Bytes, types.Bytes], bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	list, ok := m.buckets[string(m.prefix(key))]
+	return list, ok
+}
+
+func (m *hashSkipListMemTable) getOrCreateBucket(key types.Bytes) skiplist.SkipList[types.Bytes, types.Bytes] {
+	if list, ok := m.bucket(key); ok {
+		return list
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	prefix := string(m.prefix(key))
+	if list, ok := m.buckets[prefix]; ok {
+		return list
+	}
+	list := newSkipList(m.arena)
+	m.buckets[prefix] = list
+	return list
+}
+
+func (m *hashSkipListMemTable) Put(key types.Bytes, value types.Bytes) {
+	buf := m.bytes.Alloc(len(key) + len(value))
+	k := types.Bytes(buf[:len(key):len(key)])
+	v := types.Bytes(buf[len(key):])
+	copy(k, key)
+	copy(v, value)
+
+	old, replaced := m.getOrCreateBucket(k).Put(k, v)
+	m.logical.Add(int64(logicalDelta(k, v, old, replaced)))
+}
+
+func (m *hashSkipListMemTable) Get(key types.Bytes) (types.Bytes, bool) {
+	list, ok := m.bucket(key)
+	if !ok {
+		return nil, false
+	}
+	return list.Get(key)
+}
+
+// Size returns the bytes allocated from the arena of the memtable, for keys, values and skip list nodes
+func (m *hashSkipListMemTable) Size() int {
+	return m.arena.Size()
+}
+
+func (m *hashSkipListMemTable) LogicalSize() int {
+	return int(m.logical.Load())
+}
+
+func (m *hashSkipListMemTable) Scan(lower types.Bound[types.Bytes], upper types.Bound[types.Bytes]) types.ClosableIterator {
+	// both bounds within the same prefix only need its bucket
+	lp, up := lower.Data(), upper.Data()
+	if !lower.IsUnbounded() && !upper.IsUnbounded() && len(lp) >= m.prefixLen && len(up) >= m.prefixLen && bytes.Equal(m.prefix(lp), m.prefix(up)) {
+		list, ok := m.bucket(lp)
+		if !ok {
+			return newBucketMergeIter(nil)
+		}
+		return newBucketMergeIter([]skiplist.Iterator[types.Bytes, types.Bytes]{list.Scan(lower, upper)})
+	}
+
+	return newBucketMergeIter(m.iters(func(list skiplist.SkipList[types.Bytes, types.Bytes]) skiplist.Iterator[types.Bytes, types.Bytes] {
+		return list.Scan(lower, upper)
+	}))
+}
+
+func (m *hashSkipListMemTable) Iter() types.ClosableIterator {
+	return newBucketMergeIter(m.iters(func(list skiplist.SkipList[types.Bytes, types.Bytes]) skiplist.Iterator[types.Bytes, types.Bytes] {
+		return list.Iter()
+	}))
+}
+
+func (m *hashSkipListMemTable) iters(open func(skiplist.SkipList[types.Bytes, types.Bytes]) skiplist.Iterator[types.Bytes, types.Bytes]) []skiplist.Iterator[types.Bytes, types.Bytes] {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	iters := make([]skiplist.Iterator[types.Bytes, types.Bytes], 0, len(m.buckets))
+	for _, list := range m.buckets {
+		iters = append(iters, open(list))
+	}
+	return iters
+}
+
+// bucketMergeIter merges the iterators of buckets, buckets never share a key
+type bucketMergeIter struct {
+	types.ReverseIterator
+	iters []types.ClosableIterator
+}
+
+func newBucketMergeIter(lists []skiplist.Iterator[types.Bytes, types.Bytes]) types.ClosableIterator {
+	iters := make([]types.ClosableIterator, 0, len(lists))
+	merged := make([]types.Iterator, 0, len(lists))
+	for _, it := range lists {
+		wrapped := &iter{it: it}
+		iters = append(iters, wrapped)
+		merged = append(merged, wrapped)
+	}
+	return &bucketMergeIter{
+		ReverseIterator: types.NewMergeIter(merged...),
+		iters:           iters,
+	}
+}
+
+func (i *bucketMergeIter) Close() {
+	for _, it := range i.iters {
+		it.Close()
+	}
+}
@@ -46,6 +46,33 @@ func (i *iter) Next() error {
 	return nil
 }
 
+func (i *iter) Prev() error {
+	if err := i.it.Prev(); err != nil {
+		if errors.Is(err, skiplist.ErrIterEnded) {
+			return types.ErrIterEnd
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (i *iter) SeekToLast() error {
+	i.it.SeekToLast()
+	return nil
+}
+
+func (i *iter) SeekToKey(key types.Bytes) error {
+	i.it.SeekToKey(key)
+	return nil
+}
+
+func (i *iter) SeekForPrev(key types.Bytes) error {
+	i.it.SeekForPrev(key)
+	return nil
+}
+
 func (i *iter) Value() types.Bytes {
 	return i.it.Value()
 }
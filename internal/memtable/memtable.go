@@ -4,34 +4,46 @@ import (
 	"sync/atomic"
 
 	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+	"github.com/ttn-nguyen42/go-mini-lsm/pkg/arena"
 	"github.com/ttn-nguyen42/go-mini-lsm/pkg/skiplist"
 )
 
+// keys, values and skip list nodes of a memtable are allocated from slabs of this size
+const arenaSlabSize = 1 << 20
+
 type MemTable interface {
 	Put(key, value types.Bytes)
 	Get(key types.Bytes) (types.Bytes, bool)
+	// Size returns the bytes of memory held by the memtable, overwritten values included
 	Size() int
+	// LogicalSize returns the bytes of the keys and latest values, what a flush would write
+	LogicalSize() int
 	Scan(l types.Bound[types.Bytes], r types.Bound[types.Bytes]) types.ClosableIterator
 	Iter() types.ClosableIterator
 	Id() int
 }
 
 type memTable struct {
-	id   int
-	list skiplist.SkipList[types.Bytes, types.Bytes]
-	size atomic.Int32
+	id      int
+	list    skiplist.SkipList[types.Bytes, types.Bytes]
+	arena   *arena.Arena
+	bytes   *arena.Allocator[byte]
+	logical atomic.Int64
 }
 
 func New(id int) MemTable {
+	a := arena.New(arenaSlabSize)
+
 	return &memTable{
-		id:   id,
-		list: newSkipList(),
-		size: atomic.Int32{},
+		id:    id,
+		list:  newSkipList(a),
+		arena: a,
+		bytes: arena.NewAllocator[byte](a),
 	}
 }
 
-func newSkipList() skiplist.SkipList[types.Bytes, types.Bytes] {
-	res, _ := skiplist.New[types.Bytes, types.Bytes](types.BytesComparator, skiplist.WithMaxLevel(20))
+func newSkipList(a *arena.Arena) skiplist.SkipList[types.Bytes, types.Bytes] {
+	res, _ := skiplist.NewConcurrent[types.Bytes, types.Bytes](types.BytesComparator, skiplist.WithMaxLevel(20), skiplist.WithArena(a))
 
 	return res
 }
@@ -40,15 +52,33 @@ func (m *memTable) Get(key types.Bytes) (types.Bytes, bool) {
 	return m.list.Get(key)
 }
 
+// Put copies key and value into the arena of the memtable, the caller may reuse them
 func (m *memTable) Put(key types.Bytes, value types.Bytes) {
-	estSize := len(key) + len(value)
-	m.list.Put(key, value)
+	buf := m.bytes.Alloc(len(key) + len(value))
+	k := types.Bytes(buf[:len(key):len(key)])
+	v := types.Bytes(buf[len(key):])
+	copy(k, key)
+	copy(v, value)
 
-	m.size.Add(int32(estSize))
+	old, replaced := m.list.Put(k, v)
+	m.logical.Add(int64(logicalDelta(k, v, old, replaced)))
 }
 
+// logicalDelta returns the change of logical size of writing value to key over old
+func logicalDelta(key, value, old types.Bytes, replaced bool) int {
+	if replaced {
+		return len(value) - len(old)
+	}
+	return len(key) + len(value)
+}
+
+// Size returns the bytes allocated from the arena of the memtable, for keys, values and skip list nodes
 func (m *memTable) Size() int {
-	return int(m.size.Load())
+	return m.arena.Size()
+}
+
+func (m *memTable) LogicalSize() int {
+	return int(m.logical.Load())
 }
 
 func (m *memTable) Scan(lower types.Bound[types.Bytes], upper types.Bound[types.Bytes]) types.ClosableIterator {
@@ -3,6 +3,7 @@ package memtable
 import (
 	"github.com/stretchr/testify/assert"
 	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+	"strings"
 	"testing"
 )
 
@@ -46,3 +47,179 @@ func TestMemTableIter(t *testing.T) {
 	it.Close()
 	assert.Empty(t, keys)
 }
+
+func TestMemTableArena(t *testing.T) {
+	m := New(1)
+	empty := m.Size() // the head node of the skip list
+
+	key, value := []byte("key"), []byte("value")
+	m.Put(key, value)
+	// the memtable keeps its own copy
+	key[0], value[0] = 'K', 'V'
+	val, ok := m.Get([]byte("key"))
+	assert.True(t, ok)
+	assert.Equal(t, "value", string(val))
+
+	// nodes and their levels are counted with the key and value
+	size := m.Size()
+	assert.Greater(t, size, empty+len("key")+len("value"))
+
+	m.Put([]byte("key"), []byte("other"))
+	assert.Greater(t, m.Size(), size)
+}
+
+func collect(it types.ClosableIterator) []string {
+	defer it.Close()
+	var kvs []string
+	for it.HasNext() {
+		kvs = append(kvs, string(it.Key())+"="+string(it.Value()))
+		it.Next()
+	}
+	return kvs
+}
+
+func TestVectorMemTable(t *testing.T) {
+	m := NewVector(1)
+	m.Put([]byte("d"), []byte("D"))
+	m.Put([]byte("b"), []byte("B"))
+	m.Put([]byte("a"), []byte("A"))
+	m.Put([]byte("b"), []byte("B2"))
+	m.Put([]byte("c"), []byte("C"))
+
+	// looked up before sorting, the latest write wins
+	val, ok := m.Get([]byte("b"))
+	assert.True(t, ok)
+	assert.Equal(t, "B2", string(val))
+
+	m.(Freezer).Freeze()
+	val, ok = m.Get([]byte("b"))
+	assert.True(t, ok)
+	assert.Equal(t, "B2", string(val))
+	_, ok = m.Get([]byte("e"))
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{"a=A", "b=B2", "c=C", "d=D"}, collect(m.Iter()))
+	assert.Equal(t, []string{"b=B2", "c=C"}, collect(m.Scan(types.Include(types.Bytes("b")), types.Exclude(types.Bytes("d")))))
+	assert.Empty(t, collect(m.Scan(types.Exclude(types.Bytes("d")), types.Include(types.Bytes("z")))))
+
+	// writes after a scan are sorted by the next one
+	it := m.Iter()
+	m.Put([]byte("0"), []byte("Z"))
+	assert.Equal(t, []string{"a=A", "b=B2", "c=C", "d=D"}, collect(it))
+	assert.Equal(t, []string{"0=Z", "a=A", "b=B2", "c=C", "d=D"}, collect(m.Iter()))
+}
+
+func TestHashSkipListMemTable(t *testing.T) {
+	m := NewHashSkipList(2)(1)
+	m.Put([]byte("usr1"), []byte("A"))
+	m.Put([]byte("ord2"), []byte("B"))
+	m.Put([]byte("usr0"), []byte("C"))
+	m.Put([]byte("o"), []byte("D"))
+	m.Put([]byte("ord1"), []byte("E"))
+	m.Put([]byte("usr1"), []byte("F"))
+
+	val, ok := m.Get([]byte("usr1"))
+	assert.True(t, ok)
+	assert.Equal(t, "F", string(val))
+	val, ok = m.Get([]byte("o"))
+	assert.True(t, ok)
+	assert.Equal(t, "D", string(val))
+	_, ok = m.Get([]byte("xy"))
+	assert.False(t, ok)
+
+	// buckets are merged in key order
+	assert.Equal(t, []string{"o=D", "ord1=E", "ord2=B", "usr0=C", "usr1=F"}, collect(m.Iter()))
+	assert.Equal(t, []string{"ord2=B", "usr0=C"}, collect(m.Scan(types.Include(types.Bytes("ord2")), types.Include(types.Bytes("usr0")))))
+	// within a single prefix
+	assert.Equal(t, []string{"usr0=C", "usr1=F"}, collect(m.Scan(types.Include(types.Bytes("us")), types.Include(types.Bytes("usz")))))
+	assert.Empty(t, collect(m.Scan(types.Include(types.Bytes("xa")), types.Include(types.Bytes("xz")))))
+}
+
+func TestMemTableSizes(t *testing.T) {
+	for name, m := range map[string]MemTable{
+		"skiplist":      New(1),
+		"vector":        NewVector(1),
+		"hash skiplist": NewHashSkipList(1)(1),
+	} {
+		t.Run(name, func(t *testing.T) {
+			m.Put([]byte("key"), []byte("value"))
+			m.Put([]byte("other"), []byte("v"))
+			physical := m.Size()
+
+			// an overwrite replaces the value in the logical size but holds more memory
+			m.Put([]byte("key"), []byte("longer value"))
+			if f, ok := m.(Freezer); ok {
+				f.Freeze()
+			}
+			assert.Equal(t, len("key")+len("longer value")+len("other")+len("v"), m.LogicalSize())
+			assert.Greater(t, m.Size(), physical)
+			assert.Greater(t, m.Size(), m.LogicalSize())
+		})
+	}
+}
+
+func collectReverse(it types.ClosableIterator) []string {
+	defer it.Close()
+	var kvs []string
+	it.SeekToLast()
+	for it.HasNext() {
+		kvs = append(kvs, string(it.Key())+"="+string(it.Value()))
+		it.Prev()
+	}
+	return kvs
+}
+
+func TestMemTableReverse(t *testing.T) {
+	factories := map[string]Factory{
+		"skiplist":      New,
+		"vector":        NewVector,
+		"hash skiplist": NewHashSkipList(1),
+	}
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+			m := factory(1)
+			for _, k := range []string{"c", "a", "e", "b", "d"} {
+				m.Put(types.Bytes(k), types.Bytes(strings.ToUpper(k)))
+			}
+
+			assert.Equal(t, []string{"e=E", "d=D", "c=C", "b=B", "a=A"}, collectReverse(m.Iter()))
+			assert.Equal(t, []string{"d=D", "c=C"}, collectReverse(m.Scan(types.Exclude(types.Bytes("b")), types.Exclude(types.Bytes("e")))))
+
+			it := m.Iter()
+			defer it.Close()
+			assert.NoError(t, it.SeekForPrev(types.Bytes("bb")))
+			assert.Equal(t, "b", string(it.Key()))
+			assert.NoError(t, it.Next())
+			assert.Equal(t, "c", string(it.Key()))
+			assert.NoError(t, it.Prev())
+			assert.NoError(t, it.Prev())
+			assert.Equal(t, "a", string(it.Key()))
+			// moving before the first key ends the iterator
+			it.Prev()
+			assert.False(t, it.HasNext())
+			assert.ErrorIs(t, it.Prev(), types.ErrIterEnd)
+		})
+	}
+}
+
+func TestMemTableUnboundedScan(t *testing.T) {
+	factories := map[string]Factory{
+		"skiplist":      New,
+		"vector":        NewVector,
+		"hash skiplist": NewHashSkipList(1),
+	}
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+			m := factory(1)
+			for _, k := range []string{"c", "a", "b"} {
+				m.Put(types.Bytes(k), types.Bytes(strings.ToUpper(k)))
+			}
+
+			all := types.Unbounded[types.Bytes]()
+			assert.Equal(t, []string{"a=A", "b=B", "c=C"}, collect(m.Scan(all, all)))
+			assert.Equal(t, []string{"c=C", "b=B", "a=A"}, collectReverse(m.Scan(all, all)))
+			assert.Equal(t, []string{"a=A", "b=B"}, collect(m.Scan(all, types.Include(types.Bytes("b")))))
+			assert.Equal(t, []string{"c=C", "b=B"}, collectReverse(m.Scan(types.Exclude(types.Bytes("a")), all)))
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package memtable
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"unsafe"
+
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+	"github.com/ttn-nguyen42/go-mini-lsm/pkg/arena"
+)
+
+type vectorEntry struct {
+	key   types.Bytes
+	value types.Bytes
+}
+
+// vectorMemTable appends writes in their order and sorts them when frozen,
+// for bulk loads writing keys in any order and reading them only to flush.
+// Lookups before the memtable is sorted scan every write
+type vectorMemTable struct {
+	id      int
+	lock    sync.RWMutex
+	entries []vectorEntry
+	sorted  bool // entries are sorted without duplicate keys
+	logical int  // overwritten keys are only subtracted once sorted
+	arena   *arena.Arena
+	bytes   *arena.Allocator[byte]
+}
+
+func NewVector(id int) MemTable {
+	a := arena.New(arenaSlabSize)
+
+	return &vectorMemTable{
+		id:     id,
+		sorted: true,
+		arena:  a,
+		bytes:  arena.NewAllocator[byte](a),
+	}
+}
+
+func (m *vectorMemTable) Id() int {
+	return m.id
+}
+
+func (m *vectorMemTable) Put(key types.Bytes, value types.Bytes) {
+	buf := m.bytes.Alloc(len(key) + len(value))
+	e := vectorEntry{
+		key:   types.Bytes(buf[:len(key):len(key)]),
+		value: types.Bytes(buf[len(key):]),
+	}
+	copy(e.key, key)
+	copy(e.value, value)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.entries = append(m.entries, e)
+	m.sorted = false
+	m.logical += len(e.key) + len(e.value)
+}
+
+func (m *vectorMemTable) Get(key types.Bytes) (types.Bytes, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if m.sorted {
+		idx, found := slices.BinarySearchFunc(m.entries, key, func(e vectorEntry, key types.Bytes) int {
+			return types.BytesComparator(e.key, key)
+		})
+		if !found {
+			return nil, false
+		}
+		return m.entries[idx].value, true
+	}
+
+	// the latest write of key wins
+	for i := len(m.entries) - 1; i >= 0; i -= 1 {
+		if types.BytesComparator(m.entries[i].key, key) == 0 {
+			return m.entries[i].value, true
+		}
+	}
+	return nil, false
+}
+
+// Size returns the bytes of keys and values in the arena and of the entries pointing to them
+func (m *vectorMemTable) Size() int {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.arena.Size() + cap(m.entries)*int(unsafe.Sizeof(vectorEntry{}))
+}
+
+// LogicalSize counts every write until the memtable is sorted by a freeze or a scan
+func (m *vectorMemTable) LogicalSize() int {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.logical
+}
+
+func (m *vectorMemTable) Freeze() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.sort()
+}
+
+// sort replaces the entries with a sorted copy keeping the latest write of every key,
+// iterators over the previous entries are not affected
+func (m *vectorMemTable) sort() {
+	if m.sorted {
+		return
+	}
+
+	entries := slices.Clone(m.entries)
+	slices.SortStableFunc(entries, func(a, b vectorEntry) int {
+		return types.BytesComparator(a.key, b.key)
+	})
+
+	out := entries[:0]
+	m.logical = 0
+	for i, e := range entries {
+		if i+1 < len(entries) && types.BytesComparator(e.key, entries[i+1].key) == 0 {
+			continue
+		}
+		out = append(out, e)
+		m.logical += len(e.key) + len(e.value)
+	}
+
+	m.entries = slices.Clip(out)
+	m.sorted = true
+}
+
+// snapshot returns the sorted entries, sorting them first when written since the last time
+func (m *vectorMemTable) snapshot() []vectorEntry {
+	m.lock.RLock()
+	if m.sorted {
+		defer m.lock.RUnlock()
+		return m.entries
+	}
+	m.lock.RUnlock()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.sort()
+	return m.entries
+}
+
+func (m *vectorMemTable) Scan(lower types.Bound[types.Bytes], upper types.Bound[types.Bytes]) types.ClosableIterator {
+	entries := m.snapshot()
+	start := sort.Search(len(entries), func(i int) bool {
+		return !lower.IsBefore(entries[i].key, types.BytesComparator)
+	})
+	end := sort.Search(len(entries), func(i int) bool {
+		return upper.IsAfter(entries[i].key, types.BytesComparator)
+	})
+
+	return &vectorIter{entries: entries[start:max(start, end)]}
+}
+
+func (m *vectorMemTable) Iter() types.ClosableIterator {
+	return &vectorIter{entries: m.snapshot()}
+}
+
+type vectorIter struct {
+	entries []vectorEntry
+	idx     int
+}
+
+func (i *vectorIter) HasNext() bool {
+	return i.idx >= 0 && i.idx < len(i.entries)
+}
+
+func (i *vectorIter) Key() types.Bytes {
+	if !i.HasNext() {
+		panic("iterator has ended")
+	}
+	return i.entries[i.idx].key
+}
+
+func (i *vectorIter) Value() types.Bytes {
+	if !i.HasNext() {
+		panic("iterator has ended")
+	}
+	return i.entries[i.idx].value
+}
+
+func (i *vectorIter) Next() error {
+	if !i.HasNext() {
+		return types.ErrIterEnd
+	}
+	i.idx += 1
+	return nil
+}
+
+func (i *vectorIter) Prev() error {
+	if !i.HasNext() {
+		return types.ErrIterEnd
+	}
+	i.idx -= 1
+	return nil
+}
+
+func (i *vectorIter) SeekToLast() error {
+	i.idx = len(i.entries) - 1
+	return nil
+}
+
+func (i *vectorIter) SeekToKey(key types.Bytes) error {
+	i.idx = sort.Search(len(i.entries), func(j int) bool {
+		return types.BytesComparator(i.entries[j].key, key) >= 0
+	})
+	return nil
+}
+
+func (i *vectorIter) SeekForPrev(key types.Bytes) error {
+	i.idx = sort.Search(len(i.entries), func(j int) bool {
+		return types.BytesComparator(i.entries[j].key, key) > 0
+	}) - 1
+	return nil
+}
+
+func (i *vectorIter) Close() {
+	i.entries = nil
+}
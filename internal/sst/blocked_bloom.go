@@ -0,0 +1,106 @@
+package sst
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+)
+
+// every probe of a key lands in the same 64 bytes cache line
+const (
+	bloomBlockBytes = 64
+	bloomBlockBits  = bloomBlockBytes * 8
+)
+
+type blockedBloomPolicy struct {
+	bitsPerKey int
+}
+
+// NewBlockedBloomPolicy builds bloom filters split into cache line sized blocks,
+// a lookup touches a single cache line for a slightly higher false positive rate
+func NewBlockedBloomPolicy(bitsPerKey int) FilterPolicy {
+	return &blockedBloomPolicy{bitsPerKey: bitsPerKey}
+}
+
+func (p *blockedBloomPolicy) Name() string {
+	return BlockedBloomPolicyName
+}
+
+// +------------------------+--------------------+------------------+
+// | blocks (64b per block) | # of probes (1b)   |  # blocks (4b)   |
+// +------------------------+--------------------+------------------+
+func (p *blockedBloomPolicy) Build(keys []types.Bytes) ([]byte, error) {
+	nBlocks := (len(keys)*p.bitsPerKey + bloomBlockBits - 1) / bloomBlockBits
+	if nBlocks < 1 {
+		nBlocks = 1
+	}
+
+	f := &blockedBloom{
+		data:    make([]byte, nBlocks*bloomBlockBytes, nBlocks*bloomBlockBytes+1+4),
+		probes:  int(bloomProbes(p.bitsPerKey)),
+		nBlocks: uint32(nBlocks),
+	}
+	for _, k := range keys {
+		f.add(k)
+	}
+
+	data := append(f.data, byte(f.probes))
+	data = binary.BigEndian.AppendUint32(data, f.nBlocks)
+	return data, nil
+}
+
+func (p *blockedBloomPolicy) Decode(data []byte) (Filter, error) {
+	if len(data) < 1+4 {
+		return nil, fmt.Errorf("blocked bloom filter too short")
+	}
+
+	nBlocks := binary.BigEndian.Uint32(data[len(data)-4:])
+	probes := int(data[len(data)-5])
+	data = data[:len(data)-5]
+
+	if nBlocks == 0 || len(data) != int(nBlocks)*bloomBlockBytes {
+		return nil, fmt.Errorf("blocked bloom filter size mismatch: %d blocks, %d bytes", nBlocks, len(data))
+	}
+
+	return &blockedBloom{data: data, probes: probes, nBlocks: nBlocks}, nil
+}
+
+type blockedBloom struct {
+	data    []byte
+	probes  int
+	nBlocks uint32
+}
+
+// locate picks the block of a key with the upper half of its hash,
+// probes are derived from the lower half
+func (f *blockedBloom) locate(key types.Bytes) ([]byte, uint32, uint32) {
+	h := hash64(key)
+	blk := fastRange(uint32(h>>32), f.nBlocks)
+	a := uint32(h)
+	delta := (a >> 17) | (a << 15)
+
+	start := int(blk) * bloomBlockBytes
+	return f.data[start : start+bloomBlockBytes], a, delta
+}
+
+func (f *blockedBloom) add(key types.Bytes) {
+	blk, a, delta := f.locate(key)
+	for i := 0; i < f.probes; i += 1 {
+		bit := a % bloomBlockBits
+		blk[bit/8] |= 1 << (bit % 8)
+		a += delta
+	}
+}
+
+func (f *blockedBloom) MayContain(key types.Bytes) bool {
+	blk, a, delta := f.locate(key)
+	for i := 0; i < f.probes; i += 1 {
+		bit := a % bloomBlockBits
+		if blk[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+		a += delta
+	}
+	return true
+}
@@ -1,110 +1,217 @@
 package sst
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"hash/crc32"
-
-	"github.com/bits-and-blooms/bloom/v3"
+	"math"
+	"time"
 
 	"github.com/ttn-nguyen42/go-mini-lsm/internal/block"
 	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
 )
 
 type Builder struct {
-	blockBuilder *block.Builder
-	data         []byte
-	firstKey     types.Bytes
-	lastKey      types.Bytes
-	metas        []BlockMeta
-	keys         []types.Bytes
-	blockSize    uint32
+	blockBuilder    *block.Builder
+	data            []byte
+	blocks          []types.Bytes // encoded data blocks, compressed into data on Build
+	firstKey        types.Bytes
+	lastKey         types.Bytes
+	metas           []BlockMeta
+	keys            []types.Bytes
+	blockStart      int      // position in keys of the first key of the current block
+	blockFilters    [][]byte // filters of the data blocks when BlockFilters is set
+	blockSize       uint32
+	samples         [][]byte
+	sampled         int
+	dict            []byte
+	numEntries      int
+	rawSize         int
+	compressedSize  int
+	indexPartitions int
+	indexTopBlocks  int
+	topLevel        []indexEntry // top level of a partitioned index
+	opts            *BuilderOptions
 }
 
-func NewBuilder(blockSize uint32) *Builder {
+func NewBuilder(blockSize uint32, options ...BuilderOption) *Builder {
 	return &Builder{
 		metas:        make([]BlockMeta, 0),
+		blocks:       make([]types.Bytes, 0),
 		firstKey:     nil,
 		lastKey:      nil,
 		keys:         make([]types.Bytes, 0),
 		blockBuilder: block.NewBuilder(block.WithBlockSize(blockSize)),
 		blockSize:    blockSize,
+		samples:      make([][]byte, 0),
+		opts:         getBuilderOpts(options...),
 	}
 }
 
-// +-----------+-----------------+------------+-----------------+-------------------------+-------------------+--------------+--------------------+----------------+-----------------+
-// | block #0  |  checksum (4b)  |  block #1  |  checksum (4b)  |  # of met. blocks (4b)  |  metadata blocks  |  CRC32 (4b)  |  met. offset (4b)  |  bloom filter  |  bf offset (4b) |
-// +-----------+-----------------+------------+-----------------+-------------------------+-------------------+--------------+--------------------+----------------+-----------------+
+// +-----------+---------------+-----------------+-----+-----------------+-------------------------+-------------------+--------------+----------------+-----------------+--------------+--------------+-----------------+
+// | block #0  |  compr. (1b)  |  checksum (4b)  | ... |  checksum (4b)  |  # of met. blocks (4b)  |  metadata blocks  |  CRC32 (4b)  |  filter        |  block filters  |  dictionary  |  properties  |  footer (48b)   |
+// +-----------+---------------+-----------------+-----+-----------------+-------------------------+-------------------+--------------+----------------+-----------------+--------------+--------------+-----------------+
 func (b *Builder) Build(id int32, filePath string, blockCache BlockCache) (*SortedTable, error) {
-	if err := b.refreshBlock(); err != nil {
-		return nil, fmt.Errorf("failed to refresh block: %s", err)
+	if !b.blockBuilder.IsEmpty() || len(b.metas) == 0 {
+		if err := b.refreshBlock(); err != nil {
+			return nil, fmt.Errorf("failed to refresh block: %s", err)
+		}
+	}
+	if err := b.writeBlocks(); err != nil {
+		return nil, fmt.Errorf("failed to write blocks: %s", err)
 	}
 
-	bl := b.getBloomFilter()
-	blBin, err := bl.MarshalBinary() // bloom filter
+	blBin, err := b.opts.FilterPolicy.Build(b.filterKeys())
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to build filter: %s", err)
+	}
+	bl, err := b.opts.FilterPolicy.Decode(blBin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read built filter: %s", err)
+	}
+	blockFilters, err := b.decodeBlockFilters()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read built block filters: %s", err)
+	}
+	var blockFiltersBin []byte
+	if b.opts.BlockFilters {
+		blockFiltersBin = encodeBlockFilters(b.blockFilters)
 	}
 
-	s := getSstSizeEstimate(len(b.data), b.metas, len(blBin))
+	dataEnd := len(b.data)
+	index, indexHandle, err := b.encodeIndex(dataEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode index: %s", err)
+	}
+
+	props := b.properties()
+
+	s := getSstSizeEstimate(len(b.data), len(index), len(blBin)+len(blockFiltersBin), len(b.dict), props)
 	buf := make([]byte, s)
 
 	off := 0
 	copy(buf[:len(b.data)], b.data)
 	off += len(b.data)
 
-	dataChecksum := crc32.ChecksumIEEE(buf[:off])
-	binary.BigEndian.PutUint32(buf[off:off+4], dataChecksum) // block data checksum
-	off += 4
+	ft := footer{version: formatVersion, index: indexHandle}
 
-	blkMetaSize := estimateBlockMetadatas(b.metas)
-	metaOff := off
+	copy(buf[off:off+len(index)], index) // block metadatas or index partitions
+	off += len(index)
 
-	encodeBlockMetadatas(buf[off:off+blkMetaSize], b.metas) // block metadatas
-	off += blkMetaSize
+	ft.filter = BlockHandle{Offset: uint32(off), Size: uint32(len(blBin))}
+	copy(buf[off:off+len(blBin)], blBin) // filter
+	off += len(blBin)
 
-	binary.BigEndian.PutUint32(buf[off:off+4], uint32(metaOff)) // metadata offset
-	off += 4
+	props.BlockFilters = BlockHandle{Offset: uint32(off), Size: uint32(len(blockFiltersBin))}
+	copy(buf[off:off+len(blockFiltersBin)], blockFiltersBin) // filters of the data blocks
+	off += len(blockFiltersBin)
 
-	blOff := off
-	copy(buf[off:off+len(blBin)], blBin)
-	off += len(blBin)
+	ft.dict = BlockHandle{Offset: uint32(off), Size: uint32(len(b.dict))}
+	copy(buf[off:off+len(b.dict)], b.dict) // compression dictionary
+	off += len(b.dict)
 
-	binary.BigEndian.PutUint32(buf[off:off+4], uint32(blOff)) // bloom filter offset
-	off += 4
+	ft.properties = BlockHandle{Offset: uint32(off), Size: uint32(props.Size())}
+	off += props.Encode(buf[off : off+props.Size()]) // properties
 
-	return b.flushSsTable(id, buf[:off], bl, filePath, metaOff, blockCache)
+	ft.encode(buf[off : off+footerSize]) // footer
+	off += footerSize
+
+	return b.flushSsTable(id, buf[:off], bl, blockFilters, props, filePath, dataEnd, indexHandle, blockCache)
 }
 
-func getSstSizeEstimate(dataSize int, blkMeta []BlockMeta, blfSize int) int {
-	dataSize += 4                               // block data checksum
-	dataSize += estimateBlockMetadatas(blkMeta) // block metadata
-	dataSize += 4                               // metadata offset
-	dataSize += blfSize                         // bloom filter
-	dataSize += 4                               // bloom filter offset
+func getSstSizeEstimate(dataSize int, indexSize int, blfSize int, dictSize int, props *Properties) int {
+	dataSize += indexSize    // block metadata
+	dataSize += blfSize      // filters
+	dataSize += dictSize     // compression dictionary
+	dataSize += props.Size() // properties
+	dataSize += footerSize   // footer
 	return dataSize
 }
 
-func (b *Builder) Add(key types.Bytes, value types.Bytes) error {
-	if b.firstKey == nil {
-		b.firstKey = key
+// encodeIndex encodes the block metadatas, split into partitions
+// behind a top level index when the index is partitioned
+func (b *Builder) encodeIndex(offset int) ([]byte, BlockHandle, error) {
+	if b.opts.IndexPartitionSize == 0 {
+		size := estimateBlockMetadatas(b.metas)
+		index := make([]byte, size)
+		encodeBlockMetadatas(index, b.metas)
+
+		return index, BlockHandle{Offset: uint32(offset), Size: uint32(size)}, nil
 	}
 
-	if b.blockBuilder.Add(key, value) {
-		b.lastKey = key
-		b.keys = append(b.keys, key)
-		return nil
+	ib := newIndexBuilder(b.opts.IndexPartitionSize, offset)
+	for idx, m := range b.metas {
+		h := BlockHandle{Offset: m.Offset, Size: uint32(offset) - m.Offset}
+		if idx+1 < len(b.metas) {
+			h.Size = b.metas[idx+1].Offset - m.Offset
+		}
+
+		err := ib.add(indexEntry{Handle: h, BlockIdx: uint32(idx), FirstKey: m.FirstKey, LastKey: m.LastKey})
+		if err != nil {
+			return nil, BlockHandle{}, err
+		}
+	}
+
+	index, top, err := ib.finish()
+	if err != nil {
+		return nil, BlockHandle{}, err
+	}
+	b.indexPartitions = ib.partitions
+	b.indexTopBlocks = ib.topBlocks
+	b.topLevel = ib.entries
+
+	return index, top, nil
+}
+
+func (b *Builder) properties() *Properties {
+	return &Properties{
+		NumEntries:      uint64(b.numEntries),
+		NumBlocks:       uint32(len(b.metas)),
+		RawSize:         uint64(b.rawSize),
+		CompressedSize:  uint64(b.compressedSize),
+		FirstKey:        b.metas[0].FirstKey,
+		LastKey:         b.metas[len(b.metas)-1].LastKey,
+		CreatedAt:       time.Now(),
+		MinSequence:     b.opts.MinSequence,
+		MaxSequence:     b.opts.MaxSequence,
+		Compression:     b.opts.Compression,
+		IndexPartitions: uint32(b.indexPartitions),
+		IndexTopBlocks:  uint32(b.indexTopBlocks),
+		PrefixExtractor: b.prefixExtractorName(),
+		FilterPolicy:    b.opts.FilterPolicy.Name(),
 	}
+}
 
-	if err := b.refreshBlock(); err != nil {
-		return err
+func (b *Builder) prefixExtractorName() string {
+	if b.opts.PrefixExtractor == nil {
+		return ""
 	}
+	return b.opts.PrefixExtractor.Name()
+}
 
-	b.firstKey = key
+func (b *Builder) Add(key types.Bytes, value types.Bytes) error {
+	if len(key) > MaxKeySize {
+		return ErrKeyTooLarge
+	}
+	// block entries write their own size on 2 bytes
+	if 2+len(key)+2+len(value) > math.MaxUint16 {
+		return ErrEntryTooLarge
+	}
+	if b.blockBuilder.IsEmpty() {
+		b.firstKey = key
+	}
+	b.sample(key, value)
+	b.numEntries += 1
 	b.lastKey = key
-	b.keys = append(b.keys, b.firstKey)
+	b.keys = append(b.keys, key)
 
-	return nil
+	if b.blockBuilder.Add(key, value) {
+		return nil
+	}
+
+	// the block builder keeps the entry even when it overflows the block
+	return b.refreshBlock()
 }
 
 func (b *Builder) refreshBlock() error {
@@ -113,51 +220,169 @@ func (b *Builder) refreshBlock() error {
 
 	blk := currBuilder.Build()
 
-	blkData, err := block.Encode(&blk)
+	encode := block.Encode
+	if b.opts.DataBlockHashIndex {
+		encode = block.EncodeWithHashIndex
+	}
+	blkData, err := encode(&blk)
 	if err != nil {
 		return fmt.Errorf("failed to encode block data: %s", err)
 	}
 
+	// offset is only known once the block is compressed
 	blkMeta := BlockMeta{
-		Offset:   uint32(len(b.data)),
 		FirstKey: b.firstKey,
 		LastKey:  b.lastKey,
 	}
 
-	checksum := crc32.ChecksumIEEE(blkData)
-
 	b.metas = append(b.metas, blkMeta)
-	b.data = append(b.data, blkData...)
-	b.data = binary.BigEndian.AppendUint32(b.data, checksum)
+	b.blocks = append(b.blocks, blkData)
+
+	if b.opts.BlockFilters {
+		f, err := b.opts.FilterPolicy.Build(b.keys[b.blockStart:])
+		if err != nil {
+			return fmt.Errorf("failed to build block filter: %s", err)
+		}
+		b.blockFilters = append(b.blockFilters, f)
+	}
+	b.blockStart = len(b.keys)
 
 	return nil
 }
 
-func (b *Builder) getBloomFilter() *bloom.BloomFilter {
-	f := bloom.NewWithEstimates(uint(len(b.keys)), 0.01)
-	for _, k := range b.keys {
-		f.Add(k)
+// decodeBlockFilters reads back the filters of the data blocks, nil when they are not built
+func (b *Builder) decodeBlockFilters() ([]Filter, error) {
+	if !b.opts.BlockFilters {
+		return nil, nil
 	}
-	return f
+
+	filters := make([]Filter, 0, len(b.blockFilters))
+	for idx, data := range b.blockFilters {
+		f, err := b.opts.FilterPolicy.Decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read block filter idx=%d: %s", idx, err)
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// sample keeps entries around to train the compression dictionary on
+func (b *Builder) sample(key types.Bytes, value types.Bytes) {
+	if b.opts.Compression != DictCompression || b.sampled >= b.opts.SampleSize {
+		return
+	}
+
+	s := make([]byte, 0, len(key)+len(value))
+	s = append(s, key...)
+	s = append(s, value...)
+
+	b.samples = append(b.samples, s)
+	b.sampled += len(s)
 }
 
-func (b *Builder) flushSsTable(id int32, buf []byte, bl *bloom.BloomFilter, filePath string, blockMetaOffset int, blockCache BlockCache) (*SortedTable, error) {
-	headMeta := b.metas[0]
-	tailMeta := b.metas[len(b.metas)-1]
+// writeBlocks compresses the pending blocks, falling back
+// to the raw block when compression does not make it smaller
+func (b *Builder) writeBlocks() error {
+	if b.opts.Compression == DictCompression {
+		b.dict = trainDictionary(b.samples, b.opts.DictSize)
+		b.samples = nil
+	}
 
-	fo, err := Write(buf, filePath)
+	for idx, blkData := range b.blocks {
+		b.rawSize += len(blkData)
+		compression := NoCompression
+		if b.opts.Compression == DictCompression {
+			compressed, err := compressBlock(blkData, b.dict)
+			if err != nil {
+				return fmt.Errorf("failed to compress block idx=%d: %s", idx, err)
+			}
+			if len(compressed) < len(blkData) {
+				blkData = compressed
+				compression = DictCompression
+			}
+		}
+
+		b.compressedSize += len(blkData)
+		b.metas[idx].Offset = uint32(len(b.data))
+		b.data = appendBlock(b.data, blkData, compression, b.opts.DataBlockHashIndex)
+	}
+	b.blocks = b.blocks[:0]
+
+	return nil
+}
+
+// filterKeys returns whole keys, and their prefixes when a prefix extractor is set
+func (b *Builder) filterKeys() []types.Bytes {
+	prefixes := make([]types.Bytes, 0)
+	if pe := b.opts.PrefixExtractor; pe != nil {
+		for _, k := range b.keys {
+			p, ok := pe.Transform(k)
+			if !ok {
+				continue
+			}
+			// keys are sorted so equal prefixes are next to each other
+			if len(prefixes) > 0 && bytes.Equal(prefixes[len(prefixes)-1], p) {
+				continue
+			}
+			prefixes = append(prefixes, p)
+		}
+	}
+
+	keys := make([]types.Bytes, 0, len(b.keys)+len(prefixes))
+	keys = append(keys, b.keys...)
+	return append(keys, prefixes...)
+}
+
+func (b *Builder) flushSsTable(id int32, buf []byte, bl Filter, blockFilters []Filter, props *Properties, filePath string, dataEnd int, index BlockHandle, blockCache BlockCache) (*SortedTable, error) {
+	fo, err := WriteEncrypted(buf, filePath, b.opts.Encryption)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create file: %s", err)
 	}
+	if b.opts.TableCache != nil {
+		if err := fo.detach(b.opts.TableCache); err != nil {
+			return nil, fmt.Errorf("failed to close file: %s", err)
+		}
+	}
+
+	table := &SortedTable{
+		id:           id,
+		filter:       bl,
+		blockFilters: blockFilters,
+		firstKey:     props.FirstKey,
+		lastKey:      props.LastKey,
+		file:         fo,
+		dict:         b.dict,
+		props:        props,
+		cache:        blockCache,
+	}
+	if props.IndexPartitions > 0 {
+		idx := &partitionedIndex{table: table, top: index, topBlocks: int(props.IndexTopBlocks), nBlocks: len(b.metas)}
+		if b.opts.PinTopLevelIndex {
+			idx.pinned = b.topLevel
+		}
+		table.index = idx
+	} else {
+		table.index = &flatIndex{blocks: b.metas, dataEnd: dataEnd}
+	}
+
+	return table, nil
+}
+
+// blockHashIndexFlag is set in the compression type of blocks encoded with a hash index
+const blockHashIndexFlag = 0x80
+
+// appendBlock lays out a block as
+// | block | compression type (1b) | checksum (4b) |
+func appendBlock(dst []byte, blkData []byte, compression CompressionType, hashIndex bool) []byte {
+	start := len(dst)
+	dst = append(dst, blkData...)
+	if hashIndex {
+		dst = append(dst, byte(compression)|blockHashIndexFlag)
+	} else {
+		dst = append(dst, byte(compression))
+	}
 
-	return &SortedTable{
-		id:              id,
-		filter:          bl,
-		firstKey:        headMeta.FirstKey,
-		lastKey:         tailMeta.LastKey,
-		blocks:          b.metas,
-		file:            fo,
-		blockMetaOffset: blockMetaOffset,
-		cache:           blockCache,
-	}, nil
+	checksum := crc32.ChecksumIEEE(dst[start:])
+	return binary.BigEndian.AppendUint32(dst, checksum)
 }
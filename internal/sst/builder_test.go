@@ -1,7 +1,11 @@
 package sst_test
 
 import (
+	"bytes"
+	"fmt"
+	"math"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -99,3 +103,602 @@ func TestSSTIteratorMultiBlock(t *testing.T) {
 	}
 	assert.Equal(t, 20, count)
 }
+
+func TestBuilderDictCompression(t *testing.T) {
+	blockCache := sst.NewBlockCache(2048) // 2KB
+
+	build := func(path string, options ...sst.BuilderOption) *sst.SortedTable {
+		b := sst.NewBuilder(256, options...)
+		for i := range 200 {
+			key := types.Bytes(fmt.Sprintf("user:%05d", i))
+			val := types.Bytes(fmt.Sprintf(`{"id":%d,"name":"user-%d","active":true,"tags":["a","b"]}`, i, i))
+			assert.NoError(t, b.Add(key, val))
+		}
+		table, err := b.Build(1, path, blockCache)
+		assert.NoError(t, err)
+		return table
+	}
+
+	plainFile, err := os.CreateTemp("", "sstable-plain-*.sst")
+	assert.NoError(t, err)
+	defer os.Remove(plainFile.Name())
+	plain := build(plainFile.Name())
+	defer plain.Close()
+
+	dictFile, err := os.CreateTemp("", "sstable-dict-*.sst")
+	assert.NoError(t, err)
+	defer os.Remove(dictFile.Name())
+	compressed := build(dictFile.Name(), sst.WithDictCompression(4096))
+	defer compressed.Close()
+
+	assert.Less(t, compressed.File().Size(), plain.File().Size())
+
+	decoded, err := sst.Decode(2, compressed.File(), sst.NewBlockCache(2048))
+	assert.NoError(t, err)
+
+	it, err := decoded.Scan()
+	assert.NoError(t, err)
+	var count int
+	for it.HasNext() {
+		assert.Equal(t, fmt.Sprintf(`{"id":%d,"name":"user-%d","active":true,"tags":["a","b"]}`, count, count), string(it.Value()))
+		it.Next()
+		count += 1
+	}
+	assert.Equal(t, 200, count)
+}
+
+func TestBuilderProperties(t *testing.T) {
+	blockCache := sst.NewBlockCache(2048) // 2KB
+
+	b := sst.NewBuilder(32, sst.WithSequenceRange(10, 29))
+	for i := range 20 {
+		key := types.Bytes([]byte{byte('a' + i)})
+		val := types.Bytes([]byte{byte('A' + i)})
+		assert.NoError(t, b.Add(key, val))
+	}
+	tmpfile, err := os.CreateTemp("", "sstable-props-*.sst")
+	assert.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	table, err := b.Build(1, tmpfile.Name(), blockCache)
+	assert.NoError(t, err)
+	defer table.Close()
+
+	decoded, err := sst.Decode(1, table.File(), blockCache)
+	assert.NoError(t, err)
+
+	props := decoded.Properties()
+	assert.Equal(t, uint64(20), props.NumEntries)
+	assert.Equal(t, uint32(table.NumBlocks()), props.NumBlocks)
+	assert.Equal(t, types.Bytes("a"), props.FirstKey)
+	assert.Equal(t, uint64(10), props.MinSequence)
+	assert.Equal(t, uint64(29), props.MaxSequence)
+	assert.Equal(t, sst.NoCompression, props.Compression)
+	assert.Equal(t, props.RawSize, props.CompressedSize)
+	assert.Equal(t, table.Properties().CreatedAt.UnixNano(), props.CreatedAt.UnixNano())
+}
+
+func TestDecodeRejectsForeignFiles(t *testing.T) {
+	blockCache := sst.NewBlockCache(2048) // 2KB
+
+	b := sst.NewBuilder(128)
+	for i := range 5 {
+		assert.NoError(t, b.Add(types.Bytes([]byte{byte('a' + i)}), types.Bytes([]byte{byte('A' + i)})))
+	}
+	tmpfile, err := os.CreateTemp("", "sstable-foreign-*.sst")
+	assert.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	table, err := b.Build(1, tmpfile.Name(), blockCache)
+	assert.NoError(t, err)
+	assert.NoError(t, table.Close())
+
+	raw, err := os.ReadFile(tmpfile.Name())
+	assert.NoError(t, err)
+
+	for name, data := range map[string][]byte{
+		"empty":     {},
+		"foreign":   []byte("definitely not a sorted table, just some text long enough for a footer"),
+		"truncated": raw[:len(raw)-10],
+	} {
+		path := tmpfile.Name() + "." + name
+		assert.NoError(t, os.WriteFile(path, data, 0644))
+		defer os.Remove(path)
+
+		f, err := sst.Read(path)
+		assert.NoError(t, err)
+		_, err = sst.Decode(1, f, blockCache)
+		assert.ErrorIs(t, err, sst.ErrNotTable, name)
+		f.Close()
+	}
+}
+
+func TestDecodeDoesNotReadDataBlocks(t *testing.T) {
+	blockCache := sst.NewBlockCache(2048) // 2KB
+
+	b := sst.NewBuilder(32)
+	for i := range 20 {
+		assert.NoError(t, b.Add(types.Bytes([]byte{byte('a' + i)}), types.Bytes([]byte{byte('A' + i)})))
+	}
+	tmpfile, err := os.CreateTemp("", "sstable-lazy-*.sst")
+	assert.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	table, err := b.Build(1, tmpfile.Name(), blockCache)
+	assert.NoError(t, err)
+	assert.NoError(t, table.Close())
+
+	// corrupting a data block goes unnoticed until the block is read
+	raw, err := os.ReadFile(tmpfile.Name())
+	assert.NoError(t, err)
+	raw[2] ^= 0xff
+	assert.NoError(t, os.WriteFile(tmpfile.Name(), raw, 0644))
+
+	f, err := sst.Read(tmpfile.Name())
+	assert.NoError(t, err)
+	defer f.Close()
+
+	decoded, err := sst.Decode(1, f, blockCache)
+	assert.NoError(t, err)
+	assert.Equal(t, types.Bytes("a"), decoded.FirstKey())
+	assert.Equal(t, table.NumBlocks(), decoded.NumBlocks())
+}
+
+func TestPartitionedIndex(t *testing.T) {
+	blockCache := sst.NewBlockCache(2048) // 2KB
+
+	b := sst.NewBuilder(64, sst.WithPartitionedIndex(128))
+	for i := range 500 {
+		key := types.Bytes(fmt.Sprintf("key%04d", i*2))
+		val := types.Bytes(fmt.Sprintf("val%04d", i*2))
+		assert.NoError(t, b.Add(key, val))
+	}
+	tmpfile, err := os.CreateTemp("", "sstable-partitioned-*.sst")
+	assert.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	table, err := b.Build(1, tmpfile.Name(), blockCache)
+	assert.NoError(t, err)
+	defer table.Close()
+	assert.Greater(t, table.Properties().IndexPartitions, uint32(1))
+
+	pinned, err := sst.Decode(2, table.File(), blockCache, sst.PinTopLevelIndex())
+	assert.NoError(t, err)
+	unpinned, err := sst.Decode(3, table.File(), blockCache)
+	assert.NoError(t, err)
+
+	for _, tb := range []*sst.SortedTable{table, pinned, unpinned} {
+		assert.Equal(t, table.NumBlocks(), tb.NumBlocks())
+
+		it, err := tb.Scan()
+		assert.NoError(t, err)
+		var count int
+		for it.HasNext() {
+			it.Next()
+			count += 1
+		}
+		assert.Equal(t, 500, count)
+
+		for _, i := range []int{0, 1, 77, 500, 998} {
+			it, err := tb.Scan()
+			assert.NoError(t, err)
+			assert.NoError(t, it.SeekToKey(types.Bytes(fmt.Sprintf("key%04d", i))))
+			assert.True(t, it.HasNext())
+			// odd keys do not exist, seeking lands on the next one
+			want := i + i%2
+			assert.Equal(t, fmt.Sprintf("key%04d", want), string(it.Key()))
+		}
+
+		it, err = tb.Scan()
+		assert.NoError(t, err)
+		assert.NoError(t, it.SeekToKey(types.Bytes("key9999")))
+		assert.False(t, it.HasNext())
+	}
+}
+
+func TestPartitionedIndexLargeTopLevel(t *testing.T) {
+	blockCache := sst.NewBlockCache(64 << 20)
+
+	// more partitions than a single 64KB block can index
+	const n = 200000
+	b := sst.NewBuilder(64, sst.WithPartitionedIndex(256))
+	for i := range n {
+		assert.NoError(t, b.Add(types.Bytes(fmt.Sprintf("key%07d", i*2)), types.Bytes(fmt.Sprintf("val%07d", i*2))))
+	}
+	table, err := b.Build(1, filepath.Join(t.TempDir(), "large.sst"), blockCache)
+	assert.NoError(t, err)
+	defer table.Close()
+	assert.Greater(t, table.Properties().IndexTopBlocks, uint32(1))
+
+	pinned, err := sst.Decode(2, table.File(), blockCache, sst.PinTopLevelIndex())
+	assert.NoError(t, err)
+	unpinned, err := sst.Decode(3, table.File(), blockCache)
+	assert.NoError(t, err)
+
+	for _, tb := range []*sst.SortedTable{table, pinned, unpinned} {
+		assert.Equal(t, table.NumBlocks(), tb.NumBlocks())
+
+		for i := 0; i < n; i += 997 {
+			val, ok, err := tb.Get(types.Bytes(fmt.Sprintf("key%07d", i*2)))
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, fmt.Sprintf("val%07d", i*2), string(val))
+
+			_, ok, err = tb.Get(types.Bytes(fmt.Sprintf("key%07d", i*2+1)))
+			assert.NoError(t, err)
+			assert.False(t, ok)
+		}
+
+		it, err := tb.Scan()
+		assert.NoError(t, err)
+		assert.NoError(t, it.SeekToKey(types.Bytes(fmt.Sprintf("key%07d", 2*n-3))))
+		assert.True(t, it.HasNext())
+		assert.Equal(t, fmt.Sprintf("key%07d", 2*n-2), string(it.Key()))
+		assert.NoError(t, it.SeekToKey(types.Bytes(fmt.Sprintf("key%07d", 2*n))))
+		assert.False(t, it.HasNext())
+	}
+}
+
+func TestPartitionedIndexLookupAllocs(t *testing.T) {
+	blockCache := sst.NewBlockCache(64 << 20) // holds every block
+
+	b := sst.NewBuilder(64, sst.WithPartitionedIndex(256))
+	for i := range 20000 {
+		assert.NoError(t, b.Add(types.Bytes(fmt.Sprintf("key%06d", i)), types.Bytes("val")))
+	}
+	path := filepath.Join(t.TempDir(), "partitioned.sst")
+	table, err := b.Build(1, path, blockCache)
+	assert.NoError(t, err)
+	defer table.Close()
+	assert.Greater(t, table.Properties().IndexPartitions, uint32(500))
+
+	key := types.Bytes("key012345")
+	_, ok, err := table.Get(key)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// cached index blocks are searched in place, the cost does not grow with the index
+	allocs := testing.AllocsPerRun(100, func() {
+		table.Get(key)
+	})
+	assert.Less(t, allocs, float64(20))
+}
+
+func TestPinnedTopLevelIndex(t *testing.T) {
+	b := sst.NewBuilder(64, sst.WithPartitionedIndex(128), sst.WithPinnedTopLevelIndex())
+	for i := range 500 {
+		assert.NoError(t, b.Add(types.Bytes(fmt.Sprintf("key%04d", i)), types.Bytes(fmt.Sprintf("val%04d", i))))
+	}
+	pinnedCache := sst.NewBlockCache(64 << 20)
+	pinned, err := b.Build(1, filepath.Join(t.TempDir(), "pinned.sst"), pinnedCache)
+	assert.NoError(t, err)
+	defer pinned.Close()
+	assert.Greater(t, pinned.Properties().IndexPartitions, uint32(1))
+
+	unpinnedCache := sst.NewBlockCache(64 << 20)
+	unpinned, err := sst.Decode(2, pinned.File(), unpinnedCache)
+	assert.NoError(t, err)
+
+	for _, tb := range []*sst.SortedTable{pinned, unpinned} {
+		for i := range 500 {
+			val, ok, err := tb.Get(types.Bytes(fmt.Sprintf("key%04d", i)))
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, fmt.Sprintf("val%04d", i), string(val))
+		}
+	}
+
+	// the pinned table only reads partitions from the cache, not the top level
+	pinnedReads := pinnedCache.Stats().Categories["index"]
+	unpinnedReads := unpinnedCache.Stats().Categories["index"]
+	assert.Equal(t, 2*(pinnedReads.Hits+pinnedReads.Misses), unpinnedReads.Hits+unpinnedReads.Misses)
+}
+
+func TestPrefixFilter(t *testing.T) {
+	blockCache := sst.NewBlockCache(2048) // 2KB
+	pe := sst.FixedPrefix(4)
+
+	b := sst.NewBuilder(128, sst.WithPrefixExtractor(pe), sst.WithBloomBitsPerKey(20))
+	for _, user := range []string{"u001", "u003", "u005", "u007"} {
+		for i := range 10 {
+			key := types.Bytes(fmt.Sprintf("%s:%02d", user, i))
+			assert.NoError(t, b.Add(key, types.Bytes("v")))
+		}
+	}
+	tmpfile, err := os.CreateTemp("", "sstable-prefix-*.sst")
+	assert.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	table, err := b.Build(1, tmpfile.Name(), blockCache)
+	assert.NoError(t, err)
+	defer table.Close()
+
+	decoded, err := sst.Decode(1, table.File(), blockCache)
+	assert.NoError(t, err)
+	assert.Equal(t, pe.Name(), decoded.Properties().PrefixExtractor)
+
+	for _, tb := range []*sst.SortedTable{table, decoded} {
+		assert.True(t, tb.MayContainPrefix(pe, types.Bytes("u003")))
+		assert.False(t, tb.MayContainPrefix(pe, types.Bytes("u000")))
+		assert.False(t, tb.MayContainPrefix(pe, types.Bytes("u009")))
+		assert.True(t, tb.Contains(types.Bytes("u005:05")))
+
+		// without the extractor it was built with, only the key range is checked
+		assert.True(t, tb.MayContainPrefix(sst.FixedPrefix(2), types.Bytes("u004")))
+	}
+
+	misses := 0
+	for _, p := range []string{"u002", "u004", "u006"} {
+		if !decoded.MayContainPrefix(pe, types.Bytes(p)) {
+			misses += 1
+		}
+	}
+	assert.Greater(t, misses, 0)
+}
+
+func TestTableGet(t *testing.T) {
+	blockCache := sst.NewBlockCache(2048) // 2KB
+
+	for _, opts := range [][]sst.BuilderOption{nil, {sst.WithDataBlockHashIndex()}} {
+		b := sst.NewBuilder(128, opts...)
+		for i := range 100 {
+			assert.NoError(t, b.Add(types.Bytes(fmt.Sprintf("key%03d", i*2)), types.Bytes(fmt.Sprintf("val%03d", i))))
+		}
+		tmpfile, err := os.CreateTemp("", "sstable-get-*.sst")
+		assert.NoError(t, err)
+		defer os.Remove(tmpfile.Name())
+		table, err := b.Build(1, tmpfile.Name(), blockCache)
+		assert.NoError(t, err)
+		defer table.Close()
+
+		decoded, err := sst.Decode(1, table.File(), sst.NewBlockCache(2048))
+		assert.NoError(t, err)
+
+		for _, tb := range []*sst.SortedTable{table, decoded} {
+			for i := range 100 {
+				val, found, err := tb.Get(types.Bytes(fmt.Sprintf("key%03d", i*2)))
+				assert.NoError(t, err)
+				assert.True(t, found)
+				assert.Equal(t, types.Bytes(fmt.Sprintf("val%03d", i)), val)
+
+				_, found, err = tb.Get(types.Bytes(fmt.Sprintf("key%03d", i*2+1)))
+				assert.NoError(t, err)
+				assert.False(t, found)
+			}
+		}
+	}
+}
+
+// smallSetPolicy keeps the keys of small filters, larger ones let every key through
+type smallSetPolicy struct{}
+
+func (smallSetPolicy) Name() string {
+	return "small-set"
+}
+
+func (smallSetPolicy) Build(keys []types.Bytes) ([]byte, error) {
+	if len(keys) > 16 {
+		return nil, nil
+	}
+	data := make([]byte, 0)
+	for _, k := range keys {
+		data = append(data, k...)
+		data = append(data, '\n')
+	}
+	return data, nil
+}
+
+func (smallSetPolicy) Decode(data []byte) (sst.Filter, error) {
+	if len(data) == 0 {
+		return smallSetFilter(nil), nil
+	}
+	return smallSetFilter(bytes.Split(data[:len(data)-1], []byte("\n"))), nil
+}
+
+type smallSetFilter [][]byte
+
+func (f smallSetFilter) MayContain(key types.Bytes) bool {
+	if f == nil {
+		return true
+	}
+	for _, k := range f {
+		if bytes.Equal(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBlockFilters(t *testing.T) {
+	build := func(opts ...sst.BuilderOption) (*sst.SortedTable, sst.BlockCache) {
+		b := sst.NewBuilder(128, opts...)
+		for i := range 1000 {
+			assert.NoError(t, b.Add(types.Bytes(fmt.Sprintf("key%04d", i*2)), types.Bytes(fmt.Sprintf("val%04d", i))))
+		}
+		blockCache := sst.NewBlockCache(64 << 20)
+		table, err := b.Build(1, filepath.Join(t.TempDir(), "1.sst"), blockCache)
+		assert.NoError(t, err)
+		t.Cleanup(func() { table.Close() })
+		return table, blockCache
+	}
+	dataReads := func(c sst.BlockCache) uint64 {
+		reads := c.Stats().Categories["data"]
+		return reads.Hits + reads.Misses
+	}
+
+	// the table filter lets every key through, only the block filters rule keys out
+	unfiltered, unfilteredCache := build(sst.WithFilterPolicy(smallSetPolicy{}))
+	filtered, filteredCache := build(sst.WithFilterPolicy(smallSetPolicy{}), sst.WithBlockFilters())
+	assert.Zero(t, unfiltered.Properties().BlockFilters.Size)
+	assert.NotZero(t, filtered.Properties().BlockFilters.Size)
+
+	misses := make([]types.Bytes, 0)
+	for i := range 999 {
+		misses = append(misses, types.Bytes(fmt.Sprintf("key%04d", i*2+1)))
+	}
+	for _, tb := range []*sst.SortedTable{unfiltered, filtered} {
+		for i := range 1000 {
+			val, found, err := tb.Get(types.Bytes(fmt.Sprintf("key%04d", i*2)))
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, types.Bytes(fmt.Sprintf("val%04d", i)), val)
+		}
+		for _, key := range misses {
+			_, found, err := tb.Get(key)
+			assert.NoError(t, err)
+			assert.False(t, found)
+		}
+		_, found, err := tb.MultiGet(misses)
+		assert.NoError(t, err)
+		for i := range misses {
+			assert.False(t, found[i])
+		}
+	}
+	assert.Greater(t, dataReads(unfilteredCache), uint64(2000))
+	assert.Equal(t, uint64(1000), dataReads(filteredCache))
+
+	// filters of the blocks are read back with the policy recorded in the table
+	b := sst.NewBuilder(128, sst.WithBlockFilters())
+	for i := range 1000 {
+		assert.NoError(t, b.Add(types.Bytes(fmt.Sprintf("key%04d", i*2)), types.Bytes(fmt.Sprintf("val%04d", i))))
+	}
+	table, err := b.Build(2, filepath.Join(t.TempDir(), "2.sst"), sst.NewBlockCache(64<<20))
+	assert.NoError(t, err)
+	defer table.Close()
+
+	decoded, err := sst.Decode(2, table.File(), sst.NewBlockCache(64<<20))
+	assert.NoError(t, err)
+	assert.Equal(t, table.Properties().BlockFilters, decoded.Properties().BlockFilters)
+	for i := range 1000 {
+		val, found, err := decoded.Get(types.Bytes(fmt.Sprintf("key%04d", i*2)))
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, types.Bytes(fmt.Sprintf("val%04d", i)), val)
+	}
+}
+
+func TestTableMultiGet(t *testing.T) {
+	blockCache := sst.NewBlockCache(2048) // 2KB
+
+	b := sst.NewBuilder(128)
+	for i := range 100 {
+		assert.NoError(t, b.Add(types.Bytes(fmt.Sprintf("key%03d", i*2)), types.Bytes(fmt.Sprintf("val%03d", i))))
+	}
+	tmpfile, err := os.CreateTemp("", "sstable-multiget-*.sst")
+	assert.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	table, err := b.Build(1, tmpfile.Name(), blockCache)
+	assert.NoError(t, err)
+	defer table.Close()
+
+	keys := make([]types.Bytes, 0)
+	for i := range 200 {
+		keys = append(keys, types.Bytes(fmt.Sprintf("key%03d", i)))
+	}
+	keys = append(keys, types.Bytes("zzz"))
+
+	vals, found, err := table.MultiGet(keys)
+	assert.NoError(t, err)
+	for i := range 200 {
+		assert.Equal(t, i%2 == 0, found[i], "key%03d", i)
+		if i%2 == 0 {
+			assert.Equal(t, types.Bytes(fmt.Sprintf("val%03d", i/2)), vals[i])
+		}
+	}
+	assert.False(t, found[200])
+}
+
+func TestScanWithoutFillCache(t *testing.T) {
+	blockCache := sst.NewBlockCache(1 << 20)
+
+	b := sst.NewBuilder(128)
+	for i := range 100 {
+		assert.NoError(t, b.Add(types.Bytes(fmt.Sprintf("key%03d", i)), types.Bytes("v")))
+	}
+	tmpfile, err := os.CreateTemp("", "sstable-fillcache-*.sst")
+	assert.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	table, err := b.Build(1, tmpfile.Name(), blockCache)
+	assert.NoError(t, err)
+	defer table.Close()
+
+	it, err := table.Scan(sst.FillCache(false))
+	assert.NoError(t, err)
+	n := 0
+	for it.HasNext() {
+		n += 1
+		_ = it.Next()
+	}
+	assert.Equal(t, 100, n)
+	assert.Equal(t, 0, blockCache.Len())
+
+	it, err = table.Scan()
+	assert.NoError(t, err)
+	for it.HasNext() {
+		_ = it.Next()
+	}
+	assert.Equal(t, table.NumBlocks(), blockCache.Len())
+}
+
+func TestSSTIteratorReverse(t *testing.T) {
+	blockCache := sst.NewBlockCache(2048) // 2KB
+
+	b := sst.NewBuilder(64)
+	for i := range 100 {
+		key := types.Bytes(fmt.Sprintf("key%04d", i*2))
+		val := types.Bytes(fmt.Sprintf("val%04d", i*2))
+		assert.NoError(t, b.Add(key, val))
+	}
+	tmpfile, err := os.CreateTemp("", "sstable-iter-reverse-*.sst")
+	assert.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	table, err := b.Build(1, tmpfile.Name(), blockCache)
+	assert.NoError(t, err)
+	defer table.Close()
+	assert.Greater(t, table.NumBlocks(), 1)
+
+	it, err := table.Scan()
+	assert.NoError(t, err)
+	assert.NoError(t, it.SeekToLast())
+	want := 198
+	for it.HasNext() {
+		assert.Equal(t, fmt.Sprintf("key%04d", want), string(it.Key()))
+		it.Prev()
+		want -= 2
+	}
+	assert.Equal(t, -2, want)
+
+	for _, i := range []int{0, 1, 77, 198, 500} {
+		assert.NoError(t, it.SeekForPrev(types.Bytes(fmt.Sprintf("key%04d", i))))
+		assert.True(t, it.HasNext())
+		// odd keys do not exist, seeking lands on the previous one
+		want := min(i-i%2, 198)
+		assert.Equal(t, fmt.Sprintf("key%04d", want), string(it.Key()))
+	}
+
+	assert.NoError(t, it.SeekForPrev(types.Bytes("a")))
+	assert.False(t, it.HasNext())
+
+	// seeking lands on the first key of the next block when key is after a whole block
+	for i := 1; i < 198; i += 2 {
+		assert.NoError(t, it.SeekToKey(types.Bytes(fmt.Sprintf("key%04d", i))))
+		assert.Equal(t, fmt.Sprintf("key%04d", i+1), string(it.Key()))
+	}
+}
+
+func TestBuilderRejectsLongKeys(t *testing.T) {
+	blockCache := sst.NewBlockCache(2048) // 2KB
+
+	b := sst.NewBuilder(4096)
+	assert.NoError(t, b.Add(types.Bytes("a"), types.Bytes("A")))
+	assert.ErrorIs(t, b.Add(make(types.Bytes, sst.MaxKeySize+1), types.Bytes("B")), sst.ErrKeyTooLarge)
+	assert.ErrorIs(t, b.Add(types.Bytes("b"), make(types.Bytes, math.MaxUint16)), sst.ErrEntryTooLarge)
+	last := append(types.Bytes("z"), make(types.Bytes, 1000)...)
+	assert.NoError(t, b.Add(last, types.Bytes("Z")))
+
+	tmpfile, err := os.CreateTemp("", "sstable-long-keys-*.sst")
+	assert.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	table, err := b.Build(1, tmpfile.Name(), blockCache)
+	assert.NoError(t, err)
+	defer table.Close()
+
+	// the rejected key was not added
+	assert.Equal(t, uint64(2), table.Properties().NumEntries)
+	assert.Equal(t, last, table.Properties().LastKey)
+}
@@ -1,17 +1,38 @@
 package sst
 
 import (
+	"fmt"
+
 	"github.com/ttn-nguyen42/go-mini-lsm/internal/block"
 	"github.com/ttn-nguyen42/go-mini-lsm/pkg/cache"
 )
 
+type BlockKind uint8
+
+const (
+	DataBlock BlockKind = iota
+	IndexBlock
+)
+
+func (k BlockKind) String() string {
+	switch k {
+	case DataBlock:
+		return "data"
+	case IndexBlock:
+		return "index"
+	default:
+		return "unknown"
+	}
+}
+
 type CacheKey struct {
 	SstId   int32
 	BlockId int32
+	Kind    BlockKind
 }
 
 func (c CacheKey) Size() int {
-	return 4 + 4
+	return 4 + 4 + 1
 }
 
 func NewCacheKey(sstId int32, blockId int32) CacheKey {
@@ -20,6 +41,67 @@ func NewCacheKey(sstId int32, blockId int32) CacheKey {
 
 type BlockCache cache.Cache[CacheKey, *block.Block]
 
-func NewBlockCache(size int) BlockCache {
-	return cache.New[CacheKey, *block.Block](size)
+// shards of the block cache are kept large enough to hold many blocks each
+const (
+	maxBlockCacheShards = 16
+	minBlockCacheShard  = 512 * 1024
+)
+
+// NewBlockCache creates a cache holding up to size bytes of blocks,
+// sharded so that concurrent reads rarely wait on each other
+func NewBlockCache(size int, options ...cache.Option) BlockCache {
+	n := 1
+	for n < maxBlockCacheShards && size/(n*2) >= minBlockCacheShard {
+		n *= 2
+	}
+	options = append([]cache.Option{cache.WithCategorizer(blockCategory)}, options...)
+	return cache.NewSharded[CacheKey, *block.Block](size, n, options...)
+}
+
+type SecondaryBlockCache = cache.SecondaryCache[CacheKey, *block.Block]
+
+// NewSecondaryBlockCache creates a cache holding up to size bytes of compressed blocks
+// evicted from a block cache, see NewTieredBlockCache
+func NewSecondaryBlockCache(size int) *SecondaryBlockCache {
+	return cache.NewSecondary[CacheKey, *block.Block](size, blockCodec{}, cache.WithCategorizer(blockCategory))
+}
+
+// NewTieredBlockCache creates a block cache of size bytes evicting its blocks to secondary,
+// blocks found in secondary are moved back to the block cache
+func NewTieredBlockCache(size int, secondary *SecondaryBlockCache, options ...cache.Option) BlockCache {
+	options = append(options, cache.WithEvictHook(secondary.EvictHook()))
+	return cache.NewTiered(NewBlockCache(size, options...), secondary)
+}
+
+const (
+	codecPlain     = 0
+	codecHashIndex = 1
+)
+
+// blockCodec encodes blocks for the secondary cache, keeping their hash index
+type blockCodec struct{}
+
+func (blockCodec) Encode(blk *block.Block) ([]byte, error) {
+	if blk.HasHashIndex() {
+		data, err := block.EncodeWithHashIndex(blk)
+		return append([]byte{codecHashIndex}, data...), err
+	}
+	data, err := block.Encode(blk)
+	return append([]byte{codecPlain}, data...), err
+}
+
+func (blockCodec) Decode(data []byte) (*block.Block, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty block")
+	}
+	if data[0] == codecHashIndex {
+		return block.DecodeWithHashIndex(data[1:])
+	}
+	return block.Decode(data[1:])
+}
+
+// blockCategory breaks the statistics of the block cache down by kind of block,
+// filters are held by their table and never go through the cache
+func blockCategory(key any) string {
+	return key.(CacheKey).Kind.String()
 }
@@ -0,0 +1,55 @@
+package sst_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/sst"
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+)
+
+func TestTieredBlockCache(t *testing.T) {
+	secondary := sst.NewSecondaryBlockCache(1 << 20)
+	blockCache := sst.NewTieredBlockCache(256, secondary) // a couple of blocks at most
+
+	b := sst.NewBuilder(128, sst.WithDataBlockHashIndex())
+	for i := range 100 {
+		assert.NoError(t, b.Add(types.Bytes(fmt.Sprintf("key%03d", i)), types.Bytes(fmt.Sprintf("val%03d", i))))
+	}
+	table, err := b.Build(1, filepath.Join(t.TempDir(), "1.sst"), blockCache)
+	assert.NoError(t, err)
+	defer table.Close()
+
+	for range 2 {
+		for i := range 100 {
+			val, ok, err := table.Get(types.Bytes(fmt.Sprintf("key%03d", i)))
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, fmt.Sprintf("val%03d", i), string(val))
+		}
+	}
+
+	st := secondary.Stats()
+	assert.NotZero(t, st.Hits)
+	assert.NotZero(t, st.Categories["data"].Inserts)
+}
+
+func TestRowCache(t *testing.T) {
+	rowCache := sst.NewRowCache(64)
+
+	value := types.Bytes("value")
+	row := sst.NewRow(value)
+	value[0] = 'V' // rows do not share the memory of the block they were read from
+	rowCache.Set(sst.NewRowKey(1, types.Bytes("key")), row)
+	assert.Equal(t, 4+len("key")+len("value"), rowCache.SizeBytes())
+
+	got, ok := rowCache.Get(sst.NewRowKey(1, types.Bytes("key")))
+	assert.True(t, ok)
+	assert.Equal(t, "value", string(got.Value))
+
+	// the same key in another table is another row
+	_, ok = rowCache.Get(sst.NewRowKey(2, types.Bytes("key")))
+	assert.False(t, ok)
+}
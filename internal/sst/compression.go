@@ -0,0 +1,119 @@
+package sst
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"sort"
+)
+
+type CompressionType uint8
+
+const (
+	NoCompression CompressionType = iota
+	DictCompression
+)
+
+// flate can only look back 32KB, anything longer is never referenced
+const maxDictSize = 32 * 1024
+
+// length of the fragments counted when training a dictionary
+const dictSegmentSize = 16
+
+func (c CompressionType) String() string {
+	switch c {
+	case NoCompression:
+		return "none"
+	case DictCompression:
+		return "flate-dict"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(c))
+	}
+}
+
+func compressBlock(data []byte, dict []byte) ([]byte, error) {
+	buf := bytes.Buffer{}
+	w, err := flate.NewWriterDict(&buf, flate.BestCompression, dict)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompressBlock(c CompressionType, data []byte, dict []byte) ([]byte, error) {
+	switch c {
+	case NoCompression:
+		return data, nil
+	case DictCompression:
+		r := flate.NewReaderDict(bytes.NewReader(data), dict)
+		defer r.Close()
+
+		return io.ReadAll(r)
+	default:
+		return nil, fmt.Errorf("unknown compression type: %d", c)
+	}
+}
+
+// trainDictionary builds a preset dictionary from sampled entries.
+// Fragments shared by the most samples are kept, the most common ones
+// last so that flate references them with the shortest distances
+func trainDictionary(samples [][]byte, maxSize int) []byte {
+	counts := make(map[string]int)
+	for _, s := range samples {
+		seen := make(map[string]struct{})
+		for i := 0; i+dictSegmentSize <= len(s); i += dictSegmentSize / 2 {
+			seg := string(s[i : i+dictSegmentSize])
+			if _, ok := seen[seg]; ok {
+				continue
+			}
+			seen[seg] = struct{}{}
+			counts[seg] += 1
+		}
+	}
+
+	segs := make([]string, 0, len(counts))
+	for seg, n := range counts {
+		if n > 1 {
+			segs = append(segs, seg)
+		}
+	}
+	sort.Slice(segs, func(i, j int) bool {
+		if counts[segs[i]] != counts[segs[j]] {
+			return counts[segs[i]] > counts[segs[j]]
+		}
+		return segs[i] < segs[j]
+	})
+
+	if len(segs) == 0 {
+		return sampleTail(samples, maxSize)
+	}
+
+	if len(segs)*dictSegmentSize > maxSize {
+		segs = segs[:maxSize/dictSegmentSize]
+	}
+
+	dict := make([]byte, 0, len(segs)*dictSegmentSize)
+	for i := len(segs) - 1; i >= 0; i -= 1 {
+		dict = append(dict, segs[i]...)
+	}
+	return dict
+}
+
+// sampleTail concatenates the latest samples when nothing repeats enough to be trained on
+func sampleTail(samples [][]byte, maxSize int) []byte {
+	dict := make([]byte, 0, maxSize)
+	for i := len(samples) - 1; i >= 0 && len(dict) < maxSize; i -= 1 {
+		dict = append(dict, samples[i]...)
+	}
+	if len(dict) > maxSize {
+		dict = dict[:maxSize]
+	}
+	return dict
+}
@@ -0,0 +1,177 @@
+package sst
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+var ErrUnknownKey = fmt.Errorf("unknown encryption key")
+
+// EncryptionProvider encrypts files at rest. Each file starts with a header
+// naming the key it was written with, so rotated keys only apply to new files
+// while older files stay readable as long as their key is in the keyring
+type EncryptionProvider interface {
+	HeaderSize() int
+	// NewCipher creates the cipher of a new file and the header to write in front of it
+	NewCipher() (FileCipher, []byte, error)
+	// OpenCipher restores the cipher of an existing file from its header
+	OpenCipher(header []byte) (FileCipher, error)
+}
+
+// FileCipher encrypts or decrypts file content at arbitrary offsets,
+// offsets do not account for the header
+type FileCipher interface {
+	XORKeyStreamAt(dst []byte, src []byte, offset int64)
+}
+
+type Keyring struct {
+	lock   sync.RWMutex
+	keys   map[uint32][]byte
+	active uint32
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{
+		lock: sync.RWMutex{},
+		keys: make(map[uint32][]byte),
+	}
+}
+
+// Add makes a key available for reading files written with it
+func (k *Keyring) Add(id uint32, key []byte) error {
+	if _, err := aes.NewCipher(key); err != nil {
+		return fmt.Errorf("invalid key id=%d: %s", id, err)
+	}
+
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	k.keys[id] = append([]byte(nil), key...)
+	if len(k.keys) == 1 {
+		k.active = id
+	}
+	return nil
+}
+
+// Rotate adds a key and uses it for every file created from now on
+func (k *Keyring) Rotate(id uint32, key []byte) error {
+	if err := k.Add(id, key); err != nil {
+		return err
+	}
+
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	k.active = id
+	return nil
+}
+
+func (k *Keyring) Key(id uint32) ([]byte, bool) {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+
+	key, ok := k.keys[id]
+	return key, ok
+}
+
+func (k *Keyring) Active() (uint32, []byte, bool) {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+
+	key, ok := k.keys[k.active]
+	return k.active, key, ok
+}
+
+var ctrMagic = [4]byte{'M', 'L', 'E', '1'}
+
+type ctrProvider struct {
+	keyring *Keyring
+}
+
+// NewAESCTRProvider encrypts files with AES-CTR, which keeps random reads of any block possible
+//
+// +---------------+---------------+------------+
+// |  magic (4b)   |  key id (4b)  |  IV (16b)  |
+// +---------------+---------------+------------+
+func NewAESCTRProvider(keyring *Keyring) EncryptionProvider {
+	return &ctrProvider{keyring: keyring}
+}
+
+func (p *ctrProvider) HeaderSize() int {
+	return 4 + 4 + aes.BlockSize
+}
+
+func (p *ctrProvider) NewCipher() (FileCipher, []byte, error) {
+	id, key, ok := p.keyring.Active()
+	if !ok {
+		return nil, nil, ErrUnknownKey
+	}
+
+	header := make([]byte, p.HeaderSize())
+	copy(header[0:4], ctrMagic[:])
+	binary.BigEndian.PutUint32(header[4:8], id)
+
+	iv := header[8:]
+	if _, err := rand.Read(iv); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate IV: %s", err)
+	}
+
+	c, err := newCtrCipher(key, iv)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, header, nil
+}
+
+func (p *ctrProvider) OpenCipher(header []byte) (FileCipher, error) {
+	if len(header) != p.HeaderSize() || [4]byte(header[0:4]) != ctrMagic {
+		return nil, fmt.Errorf("invalid encryption header")
+	}
+
+	id := binary.BigEndian.Uint32(header[4:8])
+	key, ok := p.keyring.Key(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: id=%d", ErrUnknownKey, id)
+	}
+
+	return newCtrCipher(key, header[8:])
+}
+
+type ctrCipher struct {
+	block cipher.Block
+	iv    []byte
+}
+
+func newCtrCipher(key []byte, iv []byte) (*ctrCipher, error) {
+	blk, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &ctrCipher{block: blk, iv: append([]byte(nil), iv...)}, nil
+}
+
+func (c *ctrCipher) XORKeyStreamAt(dst []byte, src []byte, offset int64) {
+	// counter = IV + offset / block size, as a 128 bits big endian integer
+	counter := make([]byte, aes.BlockSize)
+	copy(counter, c.iv)
+
+	carry := uint64(offset / aes.BlockSize)
+	for i := aes.BlockSize - 1; i >= 0 && carry > 0; i -= 1 {
+		sum := uint64(counter[i]) + carry&0xff
+		counter[i] = byte(sum)
+		carry = carry>>8 + sum>>8
+	}
+
+	stream := cipher.NewCTR(c.block, counter)
+
+	skip := int(offset % aes.BlockSize)
+	if skip > 0 {
+		discard := make([]byte, skip)
+		stream.XORKeyStream(discard, discard)
+	}
+	stream.XORKeyStream(dst, src)
+}
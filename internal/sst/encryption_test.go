@@ -0,0 +1,74 @@
+package sst_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/sst"
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+)
+
+func buildEncrypted(t *testing.T, enc sst.EncryptionProvider) string {
+	b := sst.NewBuilder(32, sst.WithEncryption(enc))
+	for i := range 20 {
+		key := types.Bytes([]byte{'k', byte('a' + i)})
+		val := types.Bytes([]byte{'s', 'e', 'c', 'r', 'e', 't', byte('A' + i)})
+		assert.NoError(t, b.Add(key, val))
+	}
+	tmpfile, err := os.CreateTemp("", "sstable-encrypted-*.sst")
+	assert.NoError(t, err)
+	table, err := b.Build(1, tmpfile.Name(), sst.NewBlockCache(2048))
+	assert.NoError(t, err)
+	assert.NoError(t, table.Close())
+	return tmpfile.Name()
+}
+
+func readAll(t *testing.T, path string, enc sst.EncryptionProvider) []string {
+	f, err := sst.ReadEncrypted(path, enc)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	table, err := sst.Decode(1, f, sst.NewBlockCache(2048))
+	assert.NoError(t, err)
+	it, err := table.Scan()
+	assert.NoError(t, err)
+
+	var vals []string
+	for it.HasNext() {
+		vals = append(vals, string(it.Value()))
+		it.Next()
+	}
+	return vals
+}
+
+func TestEncryptionKeyRotation(t *testing.T) {
+	keyring := sst.NewKeyring()
+	assert.NoError(t, keyring.Add(1, bytes.Repeat([]byte{1}, 32)))
+	enc := sst.NewAESCTRProvider(keyring)
+
+	oldPath := buildEncrypted(t, enc)
+	defer os.Remove(oldPath)
+
+	raw, err := os.ReadFile(oldPath)
+	assert.NoError(t, err)
+	assert.False(t, bytes.Contains(raw, []byte("secret")))
+
+	assert.NoError(t, keyring.Rotate(2, bytes.Repeat([]byte{2}, 16)))
+	newPath := buildEncrypted(t, enc)
+	defer os.Remove(newPath)
+
+	oldVals := readAll(t, oldPath, enc)
+	newVals := readAll(t, newPath, enc)
+	assert.Len(t, oldVals, 20)
+	assert.Equal(t, oldVals, newVals)
+	assert.Equal(t, "secretA", oldVals[0])
+
+	// the new file cannot be read without the rotated key
+	stale := sst.NewKeyring()
+	assert.NoError(t, stale.Add(1, bytes.Repeat([]byte{1}, 32)))
+	_, err = sst.ReadEncrypted(newPath, sst.NewAESCTRProvider(stale))
+	assert.True(t, errors.Is(err, sst.ErrUnknownKey))
+}
@@ -1,20 +1,47 @@
 package sst
 
 import (
+	"fmt"
+	"io"
 	"os"
 )
 
 type FileObject struct {
-	f *os.File
-	p string
-	n int
+	f  *os.File    // nil when the file is opened through tc
+	tc *TableCache // opens the file on demand when set
+	p  string
+	n  int
+
+	cipher FileCipher
+	header int // size of the encryption header, content starts right after it
+	pos    int64
 }
 
 func Write(data []byte, path string) (*FileObject, error) {
+	return WriteEncrypted(data, path, nil)
+}
+
+// WriteEncrypted writes data encrypted with the active key of the provider, a nil provider writes plain data
+func WriteEncrypted(data []byte, path string, enc EncryptionProvider) (*FileObject, error) {
 	fo := FileObject{p: path}
 
+	out := data
+	if enc != nil {
+		c, header, err := enc.NewCipher()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher: %s", err)
+		}
+
+		out = make([]byte, len(header)+len(data))
+		copy(out, header)
+		c.XORKeyStreamAt(out[len(header):], data, 0)
+
+		fo.cipher = c
+		fo.header = len(header)
+	}
+
 	// Use 0644 for normal file creation permissions instead of os.ModeAppend
-	if err := os.WriteFile(fo.p, data, 0744); err != nil {
+	if err := os.WriteFile(fo.p, out, 0744); err != nil {
 		return nil, err
 	}
 
@@ -29,36 +56,114 @@ func Write(data []byte, path string) (*FileObject, error) {
 }
 
 func Read(path string) (*FileObject, error) {
-	fo := FileObject{}
+	return ReadEncrypted(path, nil)
+}
 
+// ReadEncrypted opens a file written by WriteEncrypted, the key is looked up from the file header
+func ReadEncrypted(path string, enc EncryptionProvider) (*FileObject, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
+
+	fo, err := readHeader(f, path, enc)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	fo.f = f
+
+	return fo, nil
+}
+
+// ReadCached is ReadEncrypted for a file kept open by tc only while it is in use
+func ReadCached(path string, enc EncryptionProvider, tc *TableCache) (*FileObject, error) {
+	of, err := tc.acquire(path)
+	if err != nil {
+		return nil, err
+	}
+	defer tc.release(of)
+
+	fo, err := readHeader(of.f, path, enc)
+	if err != nil {
+		return nil, err
+	}
+	fo.tc = tc
+
+	return fo, nil
+}
+
+func readHeader(f *os.File, path string, enc EncryptionProvider) (*FileObject, error) {
 	stats, err := f.Stat()
 	if err != nil {
 		return nil, err
 	}
 
-	fo.f = f
-	fo.p = path
-	fo.n = int(stats.Size())
+	fo := FileObject{p: path, n: int(stats.Size())}
+
+	if enc != nil {
+		header := make([]byte, enc.HeaderSize())
+		if _, err := f.ReadAt(header, 0); err != nil {
+			return nil, fmt.Errorf("failed to read encryption header: %s", err)
+		}
+
+		c, err := enc.OpenCipher(header)
+		if err != nil {
+			return nil, err
+		}
+
+		fo.cipher = c
+		fo.header = len(header)
+		fo.n -= len(header)
+	}
 
 	return &fo, nil
 }
 
+// detach closes the file, it is opened again through tc when read
+func (o *FileObject) detach(tc *TableCache) error {
+	if err := o.f.Close(); err != nil {
+		return err
+	}
+	o.f = nil
+	o.tc = tc
+	return nil
+}
+
 func (o *FileObject) Size() int {
 	return o.n
 }
 
 func (o *FileObject) Close() error {
+	if o.tc != nil {
+		return o.tc.forget(o.p)
+	}
 	return o.f.Close()
 }
 
 func (o *FileObject) Read(data []byte) (int, error) {
-	return o.f.Read(data)
+	n, err := o.ReadAt(data, o.pos)
+	o.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
 }
 
 func (o *FileObject) ReadAt(buf []byte, offset int64) (int, error) {
-	return o.f.ReadAt(buf, offset)
+	f := o.f
+	if o.tc != nil {
+		of, err := o.tc.acquire(o.p)
+		if err != nil {
+			return 0, err
+		}
+		defer o.tc.release(of)
+		f = of.f
+	}
+
+	n, err := f.ReadAt(buf, offset+int64(o.header))
+	if o.cipher != nil {
+		o.cipher.XORKeyStreamAt(buf[:n], buf[:n], offset)
+	}
+	return n, err
 }
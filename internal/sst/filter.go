@@ -0,0 +1,211 @@
+package sst
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/bits-and-blooms/bloom/v3"
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+)
+
+// FilterPolicy builds the filter of a table and reads it back.
+// The policy name is recorded in the table so the filter can be read
+// whatever policy is configured when the table is opened
+type FilterPolicy interface {
+	Name() string
+	Build(keys []types.Bytes) ([]byte, error)
+	Decode(data []byte) (Filter, error)
+}
+
+type Filter interface {
+	// MayContain returns false when key was definitely not added to the filter
+	MayContain(key types.Bytes) bool
+}
+
+const (
+	BloomPolicyName        = "bloom"
+	BlockedBloomPolicyName = "blocked-bloom"
+	RibbonPolicyName       = "ribbon"
+)
+
+// decodeFilter reads a filter written by the named policy,
+// tables written before policies were recorded use the bloom policy
+func decodeFilter(name string, data []byte) (Filter, error) {
+	var p FilterPolicy
+	switch name {
+	case "", BloomPolicyName:
+		p = NewBloomPolicy(10)
+	case BlockedBloomPolicyName:
+		p = NewBlockedBloomPolicy(10)
+	case RibbonPolicyName:
+		p = NewRibbonPolicy(10)
+	default:
+		return nil, fmt.Errorf("unknown filter policy: %s", name)
+	}
+	return p.Decode(data)
+}
+
+// encodeBlockFilters lays out the filters of the data blocks as
+// | # filters (4b) | filter len (4b) | filter | ... |
+func encodeBlockFilters(filters [][]byte) []byte {
+	data := binary.BigEndian.AppendUint32(nil, uint32(len(filters)))
+	for _, f := range filters {
+		data = binary.BigEndian.AppendUint32(data, uint32(len(f)))
+		data = append(data, f...)
+	}
+	return data
+}
+
+// decodeBlockFilters reads the filters of the data blocks written by the named policy
+func decodeBlockFilters(name string, data []byte) ([]Filter, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("block filters too short")
+	}
+	n := int(binary.BigEndian.Uint32(data[:4]))
+	off := 4
+
+	filters := make([]Filter, 0, n)
+	for i := 0; i < n; i += 1 {
+		if off+4 > len(data) {
+			return nil, fmt.Errorf("block filter idx=%d out of bounds", i)
+		}
+		size := int(binary.BigEndian.Uint32(data[off : off+4]))
+		off += 4
+		if off+size > len(data) {
+			return nil, fmt.Errorf("block filter idx=%d out of bounds", i)
+		}
+
+		f, err := decodeFilter(name, data[off:off+size])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read block filter idx=%d: %s", i, err)
+		}
+		filters = append(filters, f)
+		off += size
+	}
+
+	return filters, nil
+}
+
+type bloomPolicy struct {
+	bitsPerKey int
+}
+
+// NewBloomPolicy builds standard bloom filters with github.com/bits-and-blooms/bloom
+func NewBloomPolicy(bitsPerKey int) FilterPolicy {
+	return &bloomPolicy{bitsPerKey: bitsPerKey}
+}
+
+func (p *bloomPolicy) Name() string {
+	return BloomPolicyName
+}
+
+func (p *bloomPolicy) Build(keys []types.Bytes) ([]byte, error) {
+	f := newBloomFilter(len(keys), p.bitsPerKey)
+	for _, k := range keys {
+		f.Add(k)
+	}
+	return f.MarshalBinary()
+}
+
+func (p *bloomPolicy) Decode(data []byte) (Filter, error) {
+	bf := &bloom.BloomFilter{}
+	if _, err := bf.ReadFrom(bytes.NewBuffer(data)); err != nil {
+		return nil, fmt.Errorf("failed to read bloom filter: %s", err)
+	}
+	return &bloomFilter{f: bf}, nil
+}
+
+type bloomFilter struct {
+	f *bloom.BloomFilter
+}
+
+func (b *bloomFilter) MayContain(key types.Bytes) bool {
+	return b.f.Test(key)
+}
+
+// newBloomFilter sizes a bloom filter for n entries with bitsPerKey bits each,
+// using the number of hash functions that minimizes false positives
+func newBloomFilter(n int, bitsPerKey int) *bloom.BloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	return bloom.New(uint(n*bitsPerKey), bloomProbes(bitsPerKey))
+}
+
+func bloomProbes(bitsPerKey int) uint {
+	k := uint(math.Round(float64(bitsPerKey) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return k
+}
+
+// hash64 is FNV-1a finished with the splitmix64 mixer so every bit depends on the whole key
+func hash64(key []byte) uint64 {
+	h := uint64(14695981039346656037)
+	for _, c := range key {
+		h ^= uint64(c)
+		h *= 1099511628211
+	}
+	return mix64(h)
+}
+
+func mix64(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
+
+// fastRange maps h uniformly onto [0, n) without a division
+func fastRange(h uint32, n uint32) uint32 {
+	return uint32((uint64(h) * uint64(n)) >> 32)
+}
+
+// PrefixExtractor maps keys to the prefix indexed by the table filter
+type PrefixExtractor interface {
+	// Name is recorded in the table, filters built with another extractor are never used for prefixes
+	Name() string
+	// Transform returns the prefix of key, false when key has no prefix
+	Transform(key types.Bytes) (types.Bytes, bool)
+}
+
+type fixedPrefix struct {
+	n int
+}
+
+// FixedPrefix uses the first n bytes of keys as prefix, shorter keys have none
+func FixedPrefix(n int) PrefixExtractor {
+	return fixedPrefix{n: n}
+}
+
+func (f fixedPrefix) Name() string {
+	return fmt.Sprintf("fixed:%d", f.n)
+}
+
+func (f fixedPrefix) Transform(key types.Bytes) (types.Bytes, bool) {
+	if len(key) < f.n {
+		return nil, false
+	}
+	return key[:f.n], true
+}
+
+// MayContainPrefix returns false when no key of the table starts with prefix.
+// The filter is only used when the table was built with the same extractor
+func (s *SortedTable) MayContainPrefix(pe PrefixExtractor, prefix types.Bytes) bool {
+	if bytes.Compare(s.lastKey, prefix) < 0 {
+		return false
+	}
+	if bytes.Compare(s.firstKey, prefix) > 0 && !bytes.HasPrefix(s.firstKey, prefix) {
+		return false
+	}
+	if pe == nil || s.props.PrefixExtractor == "" || s.props.PrefixExtractor != pe.Name() {
+		return true
+	}
+
+	return s.filter.MayContain(prefix)
+}
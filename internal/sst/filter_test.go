@@ -0,0 +1,127 @@
+package sst_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/sst"
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+)
+
+func filterPolicies(bitsPerKey int) []sst.FilterPolicy {
+	return []sst.FilterPolicy{
+		sst.NewBloomPolicy(bitsPerKey),
+		sst.NewBlockedBloomPolicy(bitsPerKey),
+		sst.NewRibbonPolicy(bitsPerKey),
+	}
+}
+
+func filterKeys(prefix string, n int) []types.Bytes {
+	keys := make([]types.Bytes, n)
+	for i := range n {
+		keys[i] = types.Bytes(fmt.Sprintf("%s%08d", prefix, i))
+	}
+	return keys
+}
+
+func falsePositiveRate(f sst.Filter, probes []types.Bytes) float64 {
+	fp := 0
+	for _, k := range probes {
+		if f.MayContain(k) {
+			fp += 1
+		}
+	}
+	return float64(fp) / float64(len(probes))
+}
+
+func TestFilterPolicies(t *testing.T) {
+	keys := filterKeys("key", 10000)
+	probes := filterKeys("absent", 10000)
+
+	for _, p := range filterPolicies(10) {
+		t.Run(p.Name(), func(t *testing.T) {
+			data, err := p.Build(keys)
+			assert.NoError(t, err)
+
+			f, err := p.Decode(data)
+			assert.NoError(t, err)
+
+			for _, k := range keys {
+				assert.True(t, f.MayContain(k), "false negative for %s", k)
+			}
+			assert.Less(t, falsePositiveRate(f, probes), 0.03)
+		})
+	}
+}
+
+func TestFilterPolicyEmpty(t *testing.T) {
+	for _, p := range filterPolicies(10) {
+		data, err := p.Build(nil)
+		assert.NoError(t, err)
+
+		_, err = p.Decode(data)
+		assert.NoError(t, err)
+	}
+}
+
+func TestFilterPolicyRecorded(t *testing.T) {
+	blockCache := sst.NewBlockCache(2048) // 2KB
+
+	for _, p := range filterPolicies(10) {
+		b := sst.NewBuilder(128, sst.WithFilterPolicy(p))
+		for _, k := range filterKeys("key", 100) {
+			assert.NoError(t, b.Add(k, types.Bytes("v")))
+		}
+		tmpfile, err := os.CreateTemp("", "sstable-filter-*.sst")
+		assert.NoError(t, err)
+		defer os.Remove(tmpfile.Name())
+		table, err := b.Build(1, tmpfile.Name(), blockCache)
+		assert.NoError(t, err)
+		defer table.Close()
+
+		// decoded with the policy recorded in the table, not a configured one
+		decoded, err := sst.Decode(1, table.File(), blockCache)
+		assert.NoError(t, err)
+		assert.Equal(t, p.Name(), decoded.Properties().FilterPolicy)
+
+		for _, k := range filterKeys("key", 100) {
+			assert.True(t, decoded.Contains(k))
+		}
+	}
+}
+
+func BenchmarkFilters(b *testing.B) {
+	const n = 100000
+	keys := filterKeys("key", n)
+	probes := filterKeys("absent", n)
+
+	for _, p := range filterPolicies(10) {
+		data, err := p.Build(keys)
+		if err != nil {
+			b.Fatal(err)
+		}
+		f, err := p.Decode(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		fpRate := falsePositiveRate(f, probes)
+
+		b.Run(p.Name()+"/build", func(b *testing.B) {
+			for range b.N {
+				if _, err := p.Build(keys); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(p.Name()+"/query", func(b *testing.B) {
+			for i := range b.N {
+				f.MayContain(probes[i%n])
+			}
+			b.ReportMetric(float64(len(data)*8)/n, "bits/key")
+			b.ReportMetric(fpRate*100, "fp%")
+		})
+	}
+}
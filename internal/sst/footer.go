@@ -0,0 +1,106 @@
+package sst
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+const (
+	tableMagic    uint64 = 0x6d696e692d6c736d // "mini-lsm"
+	formatVersion uint32 = 2
+	footerSize           = 4*blockHandleSize + 4 + 4 + 8
+)
+
+var ErrNotTable = fmt.Errorf("not a sorted table")
+
+const blockHandleSize = 4 + 4
+
+// BlockHandle locates a section of the table file
+//
+// +--------------+------------+
+// | offset (4b)  |  size (4b) |
+// +--------------+------------+
+type BlockHandle struct {
+	Offset uint32
+	Size   uint32
+}
+
+func (h BlockHandle) end() int {
+	return int(h.Offset) + int(h.Size)
+}
+
+func (h BlockHandle) Encode(data []byte) int {
+	binary.BigEndian.PutUint32(data[0:4], h.Offset)
+	binary.BigEndian.PutUint32(data[4:8], h.Size)
+	return blockHandleSize
+}
+
+func (h *BlockHandle) Decode(data []byte) int {
+	h.Offset = binary.BigEndian.Uint32(data[0:4])
+	h.Size = binary.BigEndian.Uint32(data[4:8])
+	return blockHandleSize
+}
+
+// +---------------+----------------+--------------------+--------------------+----------------+---------------+--------------+
+// |  index (8b)   |  filter (8b)   |  dictionary (8b)   |  properties (8b)   |  version (4b)  |  CRC32 (4b)   |  magic (8b)  |
+// +---------------+----------------+--------------------+--------------------+----------------+---------------+--------------+
+type footer struct {
+	index      BlockHandle
+	filter     BlockHandle
+	dict       BlockHandle
+	properties BlockHandle
+	version    uint32
+}
+
+func (f *footer) encode(data []byte) {
+	off := 0
+	off += f.index.Encode(data[off:])
+	off += f.filter.Encode(data[off:])
+	off += f.dict.Encode(data[off:])
+	off += f.properties.Encode(data[off:])
+
+	binary.BigEndian.PutUint32(data[off:off+4], f.version)
+	off += 4
+
+	binary.BigEndian.PutUint32(data[off:off+4], crc32.ChecksumIEEE(data[:off]))
+	off += 4
+
+	binary.BigEndian.PutUint64(data[off:off+8], tableMagic)
+}
+
+func decodeFooter(data []byte, fileSize int) (*footer, error) {
+	if len(data) != footerSize {
+		return nil, fmt.Errorf("%w: file too short for footer", ErrNotTable)
+	}
+
+	if binary.BigEndian.Uint64(data[footerSize-8:]) != tableMagic {
+		return nil, fmt.Errorf("%w: bad magic number", ErrNotTable)
+	}
+
+	f := &footer{}
+	off := 0
+	off += f.index.Decode(data[off:])
+	off += f.filter.Decode(data[off:])
+	off += f.dict.Decode(data[off:])
+	off += f.properties.Decode(data[off:])
+
+	f.version = binary.BigEndian.Uint32(data[off : off+4])
+	off += 4
+
+	if binary.BigEndian.Uint32(data[off:off+4]) != crc32.ChecksumIEEE(data[:off]) {
+		return nil, fmt.Errorf("footer checksum mismatch")
+	}
+
+	if f.version == 0 || f.version > formatVersion {
+		return nil, fmt.Errorf("unsupported table format version: %d", f.version)
+	}
+
+	for _, h := range []BlockHandle{f.index, f.filter, f.dict, f.properties} {
+		if h.end() > fileSize-footerSize {
+			return nil, fmt.Errorf("section out of bounds: offset=%d size=%d", h.Offset, h.Size)
+		}
+	}
+
+	return f, nil
+}
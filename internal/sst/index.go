@@ -0,0 +1,437 @@
+package sst
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/block"
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+)
+
+// blockIndex locates the data blocks of a table
+type blockIndex interface {
+	numBlocks() int
+	handle(idx int) (BlockHandle, error)
+	// find returns the first block whose last key >= key, numBlocks() when key is after the table
+	find(key types.Bytes) (int, error)
+}
+
+// flatIndex keeps the metadata of every block in memory
+type flatIndex struct {
+	blocks  []BlockMeta
+	dataEnd int
+}
+
+func (f *flatIndex) numBlocks() int {
+	return len(f.blocks)
+}
+
+func (f *flatIndex) handle(idx int) (BlockHandle, error) {
+	end := f.dataEnd
+	if idx+1 < len(f.blocks) {
+		end = int(f.blocks[idx+1].Offset)
+	}
+
+	start := f.blocks[idx].Offset
+	return BlockHandle{Offset: start, Size: uint32(end) - start}, nil
+}
+
+func (f *flatIndex) find(key types.Bytes) (int, error) {
+	return sort.Search(len(f.blocks), func(i int) bool {
+		return types.BytesComparator(f.blocks[i].LastKey, key) >= 0
+	}), nil
+}
+
+// partitionedIndex is a two-level index: the top level points at index
+// partitions, themselves pointing at data blocks. Partitions are loaded
+// through the block cache, the top level too unless it is pinned. Cached
+// levels are searched in place, without decoding their entries
+type partitionedIndex struct {
+	table     *SortedTable
+	top       BlockHandle
+	topBlocks int            // blocks of the top level when a root block indexes them, 0 when it is a single block
+	pinned    decodedEntries // the top level, when kept in memory
+	nBlocks   int
+}
+
+// Key of an index entry is the last key of what it points to
+//
+// +--------------+------------+-------------------------+------------+
+// | offset (4b)  |  size (4b) |  first block idx (4b)   |  first key |
+// +--------------+------------+-------------------------+------------+
+type indexEntry struct {
+	Handle   BlockHandle
+	BlockIdx uint32
+	FirstKey types.Bytes
+	LastKey  types.Bytes
+}
+
+func (e *indexEntry) encodeValue() types.Bytes {
+	buf := make([]byte, blockHandleSize+4+len(e.FirstKey))
+	off := e.Handle.Encode(buf)
+	binary.BigEndian.PutUint32(buf[off:off+4], e.BlockIdx)
+	off += 4
+	copy(buf[off:], e.FirstKey)
+	return buf
+}
+
+func decodeIndexEntry(key types.Bytes, value types.Bytes) (indexEntry, error) {
+	if len(value) < blockHandleSize+4 {
+		return indexEntry{}, fmt.Errorf("index entry too short")
+	}
+
+	e := indexEntry{LastKey: key}
+	off := e.Handle.Decode(value)
+	e.BlockIdx = binary.BigEndian.Uint32(value[off : off+4])
+	off += 4
+	e.FirstKey = value[off:]
+
+	return e, nil
+}
+
+func decodeIndexBlock(blk *block.Block) ([]indexEntry, error) {
+	entries := blockEntries{blk: blk}
+	res := make([]indexEntry, 0, entries.len())
+	for i := 0; i < entries.len(); i += 1 {
+		e, err := entries.at(i)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// indexEntries is a level of a partitioned index, sorted by key and by block
+type indexEntries interface {
+	len() int
+	at(i int) (indexEntry, error)
+}
+
+// decodedEntries is a level kept decoded in memory
+type decodedEntries []indexEntry
+
+func (d decodedEntries) len() int {
+	return len(d)
+}
+
+func (d decodedEntries) at(i int) (indexEntry, error) {
+	return d[i], nil
+}
+
+// blockEntries reads the entries of an index block in place,
+// searching it does not copy or decode the whole block
+type blockEntries struct {
+	blk *block.Block
+}
+
+func (b blockEntries) len() int {
+	return b.blk.Len()
+}
+
+func (b blockEntries) at(i int) (indexEntry, error) {
+	return decodeIndexEntry(b.blk.KeyAt(i), b.blk.ValueAt(i))
+}
+
+// searchKey returns the position of the first entry whose last key >= key
+func searchKey(entries indexEntries, key types.Bytes) (int, error) {
+	var err error
+	pos := sort.Search(entries.len(), func(i int) bool {
+		e, eErr := entries.at(i)
+		if eErr != nil {
+			err = eErr
+			return true
+		}
+		return types.BytesComparator(e.LastKey, key) >= 0
+	})
+	return pos, err
+}
+
+// searchBlock returns the position of the last entry starting at or before block idx, -1 when there is none
+func searchBlock(entries indexEntries, idx int) (int, error) {
+	var err error
+	pos := sort.Search(entries.len(), func(i int) bool {
+		e, eErr := entries.at(i)
+		if eErr != nil {
+			err = eErr
+			return true
+		}
+		return int(e.BlockIdx) > idx
+	})
+	return pos - 1, err
+}
+
+func (p *partitionedIndex) numBlocks() int {
+	return p.nBlocks
+}
+
+// topLevel returns the top level, or the root block indexing its blocks when it has several
+func (p *partitionedIndex) topLevel() (indexEntries, error) {
+	if p.pinned != nil {
+		return p.pinned, nil
+	}
+
+	blk, err := p.table.cachedBlock(CacheKey{SstId: p.table.id, BlockId: -1, Kind: IndexBlock}, p.top)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read top level index: %s", err)
+	}
+	return blockEntries{blk: blk}, nil
+}
+
+// indexSearch returns the position of the entry to follow in a level, false when there is none
+type indexSearch func(entries indexEntries) (int, bool, error)
+
+func byKey(key types.Bytes) indexSearch {
+	return func(entries indexEntries) (int, bool, error) {
+		pos, err := searchKey(entries, key)
+		return pos, pos < entries.len(), err
+	}
+}
+
+func byBlock(idx int) indexSearch {
+	return func(entries indexEntries) (int, bool, error) {
+		pos, err := searchBlock(entries, idx)
+		return pos, pos >= 0, err
+	}
+}
+
+// follow returns the entry search leads to in entries
+func follow(entries indexEntries, search indexSearch) (indexEntry, bool, error) {
+	pos, ok, err := search(entries)
+	if err != nil || !ok {
+		return indexEntry{}, false, err
+	}
+	e, err := entries.at(pos)
+	if err != nil {
+		return indexEntry{}, false, err
+	}
+	return e, true, nil
+}
+
+// partitionFor returns the top level entry of the partition search leads to,
+// going through the root block when the top level is split in several blocks
+func (p *partitionedIndex) partitionFor(search indexSearch) (indexEntry, bool, error) {
+	top, err := p.topLevel()
+	if err != nil {
+		return indexEntry{}, false, err
+	}
+	if p.pinned != nil || p.topBlocks == 0 {
+		return follow(top, search)
+	}
+
+	pos, ok, err := search(top)
+	if err != nil || !ok {
+		return indexEntry{}, false, err
+	}
+	te, err := top.at(pos)
+	if err != nil {
+		return indexEntry{}, false, err
+	}
+	blk, err := p.table.cachedBlock(CacheKey{SstId: p.table.id, BlockId: -2 - int32(pos), Kind: IndexBlock}, te.Handle)
+	if err != nil {
+		return indexEntry{}, false, fmt.Errorf("failed to read top level index block idx=%d: %s", pos, err)
+	}
+	return follow(blockEntries{blk: blk}, search)
+}
+
+// partition returns the entries of the partition pointed at by pe, cached under its first block
+func (p *partitionedIndex) partition(pe indexEntry) (indexEntries, error) {
+	blk, err := p.table.cachedBlock(CacheKey{SstId: p.table.id, BlockId: int32(pe.BlockIdx), Kind: IndexBlock}, pe.Handle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index partition of block idx=%d: %s", pe.BlockIdx, err)
+	}
+	return blockEntries{blk: blk}, nil
+}
+
+func (p *partitionedIndex) handle(idx int) (BlockHandle, error) {
+	pe, ok, err := p.partitionFor(byBlock(idx))
+	if err != nil {
+		return BlockHandle{}, err
+	}
+	if !ok {
+		return BlockHandle{}, fmt.Errorf("block idx=%d not indexed", idx)
+	}
+
+	part, err := p.partition(pe)
+	if err != nil {
+		return BlockHandle{}, err
+	}
+
+	i := idx - int(pe.BlockIdx)
+	if i >= part.len() {
+		return BlockHandle{}, fmt.Errorf("block idx=%d not indexed", idx)
+	}
+	e, err := part.at(i)
+	if err != nil {
+		return BlockHandle{}, err
+	}
+	return e.Handle, nil
+}
+
+func (p *partitionedIndex) find(key types.Bytes) (int, error) {
+	pe, ok, err := p.partitionFor(byKey(key))
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return p.nBlocks, nil
+	}
+
+	part, err := p.partition(pe)
+	if err != nil {
+		return 0, err
+	}
+
+	i, err := searchKey(part, key)
+	if err != nil {
+		return 0, err
+	}
+	return int(pe.BlockIdx) + i, nil
+}
+
+// maxIndexBlockSize bounds index blocks, whose entries are located by 2 bytes offsets
+const maxIndexBlockSize = math.MaxUint16
+
+// ErrIndexTooLarge is returned when the root of a partitioned index does not fit in a block
+var ErrIndexTooLarge error = fmt.Errorf("partitioned index too large")
+
+// indexBuilder lays out index partitions followed by the top level index.
+// A top level too large for a block is split in blocks laid out after their
+// partitions, and indexed by a root block written last
+type indexBuilder struct {
+	partitionSize uint32
+	base          int // file offset of the index
+	data          []byte
+	top           *block.Builder
+	topFirst      indexEntry     // first partition of the current top level block
+	entries       []indexEntry   // of the top level
+	root          *block.Builder // nil until the top level needs more than a block
+	topBlocks     int
+	part          *block.Builder
+	first         indexEntry // first data block of the current partition
+	lastKey       types.Bytes
+	partitions    int
+}
+
+func newIndexBuilder(partitionSize uint32, base int) *indexBuilder {
+	partitionSize = min(partitionSize, maxIndexBlockSize)
+	return &indexBuilder{
+		partitionSize: partitionSize,
+		base:          base,
+		data:          make([]byte, 0),
+		top:           block.NewBuilder(block.WithBlockSize(maxIndexBlockSize)),
+		part:          block.NewBuilder(block.WithBlockSize(partitionSize)),
+	}
+}
+
+func (ib *indexBuilder) add(e indexEntry) error {
+	if ib.part.IsEmpty() {
+		ib.first = e
+	}
+	ib.lastKey = e.LastKey
+
+	if ib.part.Add(e.LastKey, e.encodeValue()) {
+		return nil
+	}
+	return ib.flushPartition()
+}
+
+// appendIndexBlock lays out blk after the index blocks written so far
+func (ib *indexBuilder) appendIndexBlock(blk *block.Block) (BlockHandle, error) {
+	blkData, err := block.Encode(blk)
+	if err != nil {
+		return BlockHandle{}, err
+	}
+
+	start := len(ib.data)
+	ib.data = appendBlock(ib.data, blkData, NoCompression, false)
+	return BlockHandle{Offset: uint32(ib.base + start), Size: uint32(len(ib.data) - start)}, nil
+}
+
+func (ib *indexBuilder) flushPartition() error {
+	blk := ib.part.Build()
+	ib.part = block.NewBuilder(block.WithBlockSize(ib.partitionSize))
+
+	h, err := ib.appendIndexBlock(&blk)
+	if err != nil {
+		return fmt.Errorf("failed to encode index partition: %s", err)
+	}
+
+	e := indexEntry{
+		Handle:   h,
+		BlockIdx: ib.first.BlockIdx,
+		FirstKey: ib.first.FirstKey,
+		LastKey:  ib.lastKey,
+	}
+	ib.entries = append(ib.entries, e)
+	ib.partitions += 1
+
+	if ib.top.IsEmpty() {
+		ib.topFirst = e
+	}
+	if ib.top.Add(e.LastKey, e.encodeValue()) {
+		return nil
+	}
+	// the block builder keeps the entry even when it overflows the block
+	return ib.flushTop()
+}
+
+// flushTop writes the current block of the top level and indexes it in the root
+func (ib *indexBuilder) flushTop() error {
+	blk := ib.top.Build()
+	ib.top = block.NewBuilder(block.WithBlockSize(maxIndexBlockSize))
+
+	h, err := ib.appendIndexBlock(&blk)
+	if err != nil {
+		return fmt.Errorf("failed to encode top level index block: %s", err)
+	}
+
+	e := indexEntry{
+		Handle:   h,
+		BlockIdx: ib.topFirst.BlockIdx,
+		FirstKey: ib.topFirst.FirstKey,
+		LastKey:  ib.lastKey,
+	}
+	if ib.root == nil {
+		ib.root = block.NewBuilder(block.WithBlockSize(maxIndexBlockSize))
+	}
+	ib.topBlocks += 1
+
+	if !ib.root.Add(e.LastKey, e.encodeValue()) {
+		return ErrIndexTooLarge
+	}
+	return nil
+}
+
+// finish returns the encoded index and the handle of its top level,
+// or of the root block when the top level is split in blocks
+func (ib *indexBuilder) finish() ([]byte, BlockHandle, error) {
+	if !ib.part.IsEmpty() {
+		if err := ib.flushPartition(); err != nil {
+			return nil, BlockHandle{}, err
+		}
+	}
+
+	if ib.root == nil {
+		blk := ib.top.Build()
+		h, err := ib.appendIndexBlock(&blk)
+		if err != nil {
+			return nil, BlockHandle{}, fmt.Errorf("failed to encode top level index: %s", err)
+		}
+		return ib.data, h, nil
+	}
+
+	if !ib.top.IsEmpty() {
+		if err := ib.flushTop(); err != nil {
+			return nil, BlockHandle{}, err
+		}
+	}
+	blk := ib.root.Build()
+	h, err := ib.appendIndexBlock(&blk)
+	if err != nil {
+		return nil, BlockHandle{}, fmt.Errorf("failed to encode root index: %s", err)
+	}
+	return ib.data, h, nil
+}
@@ -12,10 +12,11 @@ type iter struct {
 	table    *SortedTable
 	blkIter  types.SeekableIterator
 	blkIndex int
+	opts     *ReadOptions
 }
 
-func newIter(table *SortedTable) types.SeekableIterator {
-	first, ok, err := table.Block(0)
+func newIter(table *SortedTable, opts *ReadOptions) types.SeekableIterator {
+	first, ok, err := table.block(0, opts.FillCache)
 	if err != nil {
 		if errors.Is(err, block.ErrBlockEmpty) {
 			first = nil
@@ -30,6 +31,7 @@ func newIter(table *SortedTable) types.SeekableIterator {
 	it := &iter{
 		table:    table,
 		blkIndex: 0,
+		opts:     opts,
 	}
 	if first != nil {
 		it.blkIter = first.Scan()
@@ -52,7 +54,7 @@ func (i *iter) Next() error {
 			return err
 		}
 		i.blkIndex += 1
-		blk, ok, err := i.table.Block(i.blkIndex)
+		blk, ok, err := i.table.block(i.blkIndex, i.opts.FillCache)
 		if err != nil {
 			return fmt.Errorf("failed to get next block: %s", err)
 		}
@@ -66,15 +68,43 @@ func (i *iter) Next() error {
 	return nil
 }
 
+func (i *iter) Prev() error {
+	if !i.HasNext() {
+		return types.ErrIterEnd
+	}
+
+	err := i.blkIter.Prev()
+	if err != nil {
+		if !errors.Is(err, types.ErrIterEnd) {
+			return err
+		}
+		if i.blkIndex == 0 {
+			i.blkIter = nil
+			return types.ErrIterEnd
+		}
+		if err := i.Seek(i.blkIndex - 1); err != nil {
+			return fmt.Errorf("failed to get previous block: %s", err)
+		}
+		return i.blkIter.SeekToLast()
+	}
+
+	return nil
+}
+
 func (i *iter) Value() types.Bytes {
 	return i.blkIter.Value()
 }
 
 func (i *iter) Seek(idx int) error {
-	blk, _, err := i.table.Block(idx)
+	blk, ok, err := i.table.block(idx, i.opts.FillCache)
 	if err != nil {
 		return err
 	}
+	if !ok {
+		i.blkIndex = idx
+		i.blkIter = nil
+		return nil
+	}
 	blkIter := blk.Scan()
 
 	i.blkIndex = idx
@@ -84,20 +114,63 @@ func (i *iter) Seek(idx int) error {
 }
 
 func (i *iter) SeekToKey(key types.Bytes) error {
-	for idx, meta := range i.table.blocks {
-		if types.BytesComparator(key, meta.FirstKey) < 0 {
-			continue
-		}
-		err := i.Seek(idx)
-		if err != nil {
-			return err
-		}
-		err = i.blkIter.SeekToKey(key)
-		if err != nil {
+	idx, err := i.table.findBlock(key)
+	if err != nil {
+		return err
+	}
+	if idx >= i.table.NumBlocks() {
+		// key is after the whole table
+		i.blkIndex = idx
+		i.blkIter = nil
+		return nil
+	}
+
+	if err := i.Seek(idx); err != nil {
+		return err
+	}
+	if err := i.blkIter.SeekToKey(key); err != nil {
+		return err
+	}
+	if i.blkIter.HasNext() && types.BytesComparator(i.blkIter.Key(), key) < 0 {
+		// every key of the block is before key, the next block starts after it
+		return i.Seek(idx + 1)
+	}
+	return nil
+}
+
+func (i *iter) SeekToLast() error {
+	if i.table.NumBlocks() == 0 {
+		i.blkIter = nil
+		return nil
+	}
+	if err := i.Seek(i.table.NumBlocks() - 1); err != nil {
+		return err
+	}
+	return i.blkIter.SeekToLast()
+}
+
+func (i *iter) SeekForPrev(key types.Bytes) error {
+	idx, err := i.table.findBlock(key)
+	if err != nil {
+		return err
+	}
+	if idx >= i.table.NumBlocks() {
+		// key is after the whole table
+		return i.SeekToLast()
+	}
+
+	if err := i.Seek(idx); err != nil {
+		return err
+	}
+	if err := i.blkIter.SeekForPrev(key); err != nil {
+		return err
+	}
+	if !i.blkIter.HasNext() && idx > 0 {
+		// every key of the block is after key, the previous block ends before it
+		if err := i.Seek(idx - 1); err != nil {
 			return err
 		}
-		break
+		return i.blkIter.SeekToLast()
 	}
-
 	return nil
 }
@@ -127,6 +127,9 @@ func encodeBlockMetadatas(data []byte, metadata []BlockMeta) {
 }
 
 func decodeBlockMetadatas(data []byte) ([]BlockMeta, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("metadata blocks too short")
+	}
 	rawNum := data[:4]
 	n := binary.BigEndian.Uint32(rawNum)
 	metadata := make([]BlockMeta, n)
@@ -0,0 +1,192 @@
+package sst
+
+type BuilderOptions struct {
+	Compression CompressionType
+	DictSize    int // maximum size of the trained dictionary
+	SampleSize  int // how many bytes of entries are sampled to train the dictionary
+	Encryption  EncryptionProvider
+	MinSequence uint64
+	MaxSequence uint64
+
+	IndexPartitionSize uint32 // size of index partitions, 0 keeps a single level index
+	PinTopLevelIndex   bool   // the built table keeps the top level of its partitioned index in memory
+	DataBlockHashIndex bool   // data blocks carry a hash index of their keys for point lookups
+	BlockFilters       bool   // data blocks get their own filter so point lookups skip the blocks it rules out
+
+	TableCache *TableCache // the built table opens its file through it, nil keeps the file open
+
+	BloomBitsPerKey int
+	FilterPolicy    FilterPolicy // bloom filter with BloomBitsPerKey when nil
+	PrefixExtractor PrefixExtractor
+}
+
+type BuilderOption func(opts *BuilderOptions)
+
+func getBuilderOpts(options ...BuilderOption) *BuilderOptions {
+	defOpts := &BuilderOptions{
+		Compression: NoCompression,
+		DictSize:    16 * 1024,
+		SampleSize:  0,
+
+		BloomBitsPerKey: 10, // ~1% false positives
+	}
+
+	for _, opt := range options {
+		opt(defOpts)
+	}
+
+	if defOpts.DictSize > maxDictSize {
+		defOpts.DictSize = maxDictSize
+	}
+	if defOpts.BloomBitsPerKey <= 0 {
+		defOpts.BloomBitsPerKey = 10
+	}
+	if defOpts.FilterPolicy == nil {
+		defOpts.FilterPolicy = NewBloomPolicy(defOpts.BloomBitsPerKey)
+	}
+	if defOpts.SampleSize <= 0 {
+		defOpts.SampleSize = defOpts.DictSize * 100
+	}
+
+	return defOpts
+}
+
+// WithDictCompression compresses every data block with a dictionary
+// trained on the entries added to the builder
+func WithDictCompression(dictSize int) BuilderOption {
+	return func(opts *BuilderOptions) {
+		opts.Compression = DictCompression
+		opts.DictSize = dictSize
+	}
+}
+
+func WithDictSampleSize(size int) BuilderOption {
+	return func(opts *BuilderOptions) {
+		opts.SampleSize = size
+	}
+}
+
+func WithEncryption(enc EncryptionProvider) BuilderOption {
+	return func(opts *BuilderOptions) {
+		opts.Encryption = enc
+	}
+}
+
+// WithSequenceRange records the sequence numbers of the entries written to the table in its properties
+func WithSequenceRange(min uint64, max uint64) BuilderOption {
+	return func(opts *BuilderOptions) {
+		opts.MinSequence = min
+		opts.MaxSequence = max
+	}
+}
+
+// WithPartitionedIndex splits the index into partitions of about partitionSize bytes,
+// only the small top level index has to be read when opening the table
+func WithPartitionedIndex(partitionSize uint32) BuilderOption {
+	return func(opts *BuilderOptions) {
+		opts.IndexPartitionSize = partitionSize
+	}
+}
+
+// WithPinnedTopLevelIndex is PinTopLevelIndex for the built table
+func WithPinnedTopLevelIndex() BuilderOption {
+	return func(opts *BuilderOptions) {
+		opts.PinTopLevelIndex = true
+	}
+}
+
+// WithDataBlockHashIndex appends a hash index to every data block, point lookups
+// find their entry without a binary search for a few more bytes per entry
+func WithDataBlockHashIndex() BuilderOption {
+	return func(opts *BuilderOptions) {
+		opts.DataBlockHashIndex = true
+	}
+}
+
+// WithBlockFilters builds a filter of every data block with the filter policy,
+// point lookups the table filter lets through only read the block when its filter does too
+func WithBlockFilters() BuilderOption {
+	return func(opts *BuilderOptions) {
+		opts.BlockFilters = true
+	}
+}
+
+// WithTableCache closes the file of the built table, it is opened again on demand through tc
+func WithTableCache(tc *TableCache) BuilderOption {
+	return func(opts *BuilderOptions) {
+		opts.TableCache = tc
+	}
+}
+
+// WithBloomBitsPerKey trades filter size for false positives, 10 bits per key gives about 1%
+func WithBloomBitsPerKey(bitsPerKey int) BuilderOption {
+	return func(opts *BuilderOptions) {
+		opts.BloomBitsPerKey = bitsPerKey
+	}
+}
+
+// WithFilterPolicy picks how the table filter is built, see NewBloomPolicy,
+// NewBlockedBloomPolicy and NewRibbonPolicy
+func WithFilterPolicy(p FilterPolicy) BuilderOption {
+	return func(opts *BuilderOptions) {
+		opts.FilterPolicy = p
+	}
+}
+
+// WithPrefixExtractor also indexes key prefixes in the filter so prefix scans can skip the table
+func WithPrefixExtractor(pe PrefixExtractor) BuilderOption {
+	return func(opts *BuilderOptions) {
+		opts.PrefixExtractor = pe
+	}
+}
+
+type TableOptions struct {
+	PinTopLevelIndex bool
+}
+
+type TableOption func(opts *TableOptions)
+
+func getTableOpts(options ...TableOption) *TableOptions {
+	defOpts := &TableOptions{
+		PinTopLevelIndex: false,
+	}
+
+	for _, opt := range options {
+		opt(defOpts)
+	}
+
+	return defOpts
+}
+
+// PinTopLevelIndex keeps the top level of a partitioned index in memory instead of in the block cache
+func PinTopLevelIndex() TableOption {
+	return func(opts *TableOptions) {
+		opts.PinTopLevelIndex = true
+	}
+}
+
+type ReadOptions struct {
+	FillCache bool // blocks read from the file are added to the block cache
+}
+
+type ReadOption func(opts *ReadOptions)
+
+func getReadOpts(options ...ReadOption) *ReadOptions {
+	defOpts := &ReadOptions{
+		FillCache: true,
+	}
+
+	for _, opt := range options {
+		opt(defOpts)
+	}
+
+	return defOpts
+}
+
+// FillCache set to false still uses blocks already in the cache but does not add
+// the blocks it reads, so that large scans do not evict the blocks of point lookups
+func FillCache(fill bool) ReadOption {
+	return func(opts *ReadOptions) {
+		opts.FillCache = fill
+	}
+}
@@ -0,0 +1,212 @@
+package sst
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"time"
+
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+)
+
+// MaxKeySize is the longest key a table holds, key lengths are written on 2 bytes
+const MaxKeySize = math.MaxUint16
+
+var (
+	ErrKeyTooLarge   = fmt.Errorf("key longer than %d bytes", MaxKeySize)
+	ErrEntryTooLarge = fmt.Errorf("entry longer than %d bytes", math.MaxUint16)
+)
+
+// Properties describes the content of a table, stored in its own meta-block
+type Properties struct {
+	NumEntries     uint64
+	NumBlocks      uint32
+	RawSize        uint64 // size of the data blocks before compression
+	CompressedSize uint64 // size of the data blocks on disk
+	FirstKey       types.Bytes
+	LastKey        types.Bytes
+	CreatedAt      time.Time
+	MinSequence    uint64
+	MaxSequence    uint64
+	Compression    CompressionType
+
+	IndexPartitions uint32      // number of index partitions, 0 when the index has a single level
+	PrefixExtractor string      // name of the extractor whose prefixes are in the filter, empty when none
+	FilterPolicy    string      // name of the policy the filter was built with, empty for bloom
+	IndexTopBlocks  uint32      // blocks of the top level of a partitioned index indexed by a root block, 0 when it is a single block
+	BlockFilters    BlockHandle // filters of every data block, empty when the table has none
+}
+
+// +------------------+-----------------+---------------+----------------------+-----------------------+
+// | # entries (8b)   | # blocks (4b)   | raw size (8b) | compressed size (8b) | created at (8b, nano) |
+// +------------------+-----------------+---------------+----------------------+-----------------------+
+// +-------------------+-------------------+----------------+---------------------+-------------+---------------------+-----------+
+// | min seq. (8b)     | max seq. (8b)     | compr. (1b)    | first key len (2b)  | first key   | last key len (2b)   | last key  |
+// +-------------------+-------------------+----------------+---------------------+-------------+---------------------+-----------+
+// +---------------------------+-----------------------------+-------------------+--------------------------+-----------------+---------------------------+----------------------+---------------+
+// | # index partitions (4b)   |  prefix extractor len (2b)  |  prefix extractor |  filter policy len (2b)  |  filter policy  |  # index top blocks (4b)  |  block filters (8b)  |  CRC32 (4b)   |
+// +---------------------------+-----------------------------+-------------------+--------------------------+-----------------+---------------------------+----------------------+---------------+
+//
+// Fields after the last key are optional so that tables written before they existed still decode
+func (p *Properties) Size() int {
+	return 8 + 4 + 8 + 8 + 8 + 8 + 8 + 1 + 2 + len(p.FirstKey) + 2 + len(p.LastKey) + 4 + 2 + len(p.PrefixExtractor) + 2 + len(p.FilterPolicy) + 4 + blockHandleSize + 4
+}
+
+func (p *Properties) Encode(data []byte) int {
+	off := 0
+
+	binary.BigEndian.PutUint64(data[off:off+8], p.NumEntries)
+	off += 8
+
+	binary.BigEndian.PutUint32(data[off:off+4], p.NumBlocks)
+	off += 4
+
+	binary.BigEndian.PutUint64(data[off:off+8], p.RawSize)
+	off += 8
+
+	binary.BigEndian.PutUint64(data[off:off+8], p.CompressedSize)
+	off += 8
+
+	binary.BigEndian.PutUint64(data[off:off+8], uint64(p.CreatedAt.UnixNano()))
+	off += 8
+
+	binary.BigEndian.PutUint64(data[off:off+8], p.MinSequence)
+	off += 8
+
+	binary.BigEndian.PutUint64(data[off:off+8], p.MaxSequence)
+	off += 8
+
+	data[off] = byte(p.Compression)
+	off += 1
+
+	binary.BigEndian.PutUint16(data[off:off+2], uint16(len(p.FirstKey)))
+	off += 2
+
+	copy(data[off:off+len(p.FirstKey)], p.FirstKey)
+	off += len(p.FirstKey)
+
+	binary.BigEndian.PutUint16(data[off:off+2], uint16(len(p.LastKey)))
+	off += 2
+
+	copy(data[off:off+len(p.LastKey)], p.LastKey)
+	off += len(p.LastKey)
+
+	binary.BigEndian.PutUint32(data[off:off+4], p.IndexPartitions)
+	off += 4
+
+	binary.BigEndian.PutUint16(data[off:off+2], uint16(len(p.PrefixExtractor)))
+	off += 2
+
+	copy(data[off:off+len(p.PrefixExtractor)], p.PrefixExtractor)
+	off += len(p.PrefixExtractor)
+
+	binary.BigEndian.PutUint16(data[off:off+2], uint16(len(p.FilterPolicy)))
+	off += 2
+
+	copy(data[off:off+len(p.FilterPolicy)], p.FilterPolicy)
+	off += len(p.FilterPolicy)
+
+	binary.BigEndian.PutUint32(data[off:off+4], p.IndexTopBlocks)
+	off += 4
+
+	off += p.BlockFilters.Encode(data[off : off+blockHandleSize])
+
+	binary.BigEndian.PutUint32(data[off:off+4], crc32.ChecksumIEEE(data[:off]))
+	off += 4
+
+	return off
+}
+
+func decodeProperties(data []byte) (*Properties, error) {
+	if len(data) < 8+4+8+8+8+8+8+1+2+2+4 {
+		return nil, fmt.Errorf("properties block too short")
+	}
+
+	cs := binary.BigEndian.Uint32(data[len(data)-4:])
+	data = data[:len(data)-4]
+	if crc32.ChecksumIEEE(data) != cs {
+		return nil, fmt.Errorf("invalid properties checksum")
+	}
+
+	p := &Properties{}
+	off := 0
+
+	p.NumEntries = binary.BigEndian.Uint64(data[off : off+8])
+	off += 8
+
+	p.NumBlocks = binary.BigEndian.Uint32(data[off : off+4])
+	off += 4
+
+	p.RawSize = binary.BigEndian.Uint64(data[off : off+8])
+	off += 8
+
+	p.CompressedSize = binary.BigEndian.Uint64(data[off : off+8])
+	off += 8
+
+	p.CreatedAt = time.Unix(0, int64(binary.BigEndian.Uint64(data[off:off+8])))
+	off += 8
+
+	p.MinSequence = binary.BigEndian.Uint64(data[off : off+8])
+	off += 8
+
+	p.MaxSequence = binary.BigEndian.Uint64(data[off : off+8])
+	off += 8
+
+	p.Compression = CompressionType(data[off])
+	off += 1
+
+	firstKeyLen := int(binary.BigEndian.Uint16(data[off : off+2]))
+	off += 2
+	if off+firstKeyLen+2 > len(data) {
+		return nil, fmt.Errorf("properties first key out of bounds")
+	}
+
+	p.FirstKey = append(types.Bytes(nil), data[off:off+firstKeyLen]...)
+	off += firstKeyLen
+
+	lastKeyLen := int(binary.BigEndian.Uint16(data[off : off+2]))
+	off += 2
+	if off+lastKeyLen > len(data) {
+		return nil, fmt.Errorf("properties last key out of bounds")
+	}
+
+	p.LastKey = append(types.Bytes(nil), data[off:off+lastKeyLen]...)
+	off += lastKeyLen
+
+	if off+4 <= len(data) {
+		p.IndexPartitions = binary.BigEndian.Uint32(data[off : off+4])
+		off += 4
+	}
+
+	if off+2 <= len(data) {
+		nameLen := int(binary.BigEndian.Uint16(data[off : off+2]))
+		off += 2
+		if off+nameLen > len(data) {
+			return nil, fmt.Errorf("properties prefix extractor out of bounds")
+		}
+		p.PrefixExtractor = string(data[off : off+nameLen])
+		off += nameLen
+	}
+
+	if off+2 <= len(data) {
+		nameLen := int(binary.BigEndian.Uint16(data[off : off+2]))
+		off += 2
+		if off+nameLen > len(data) {
+			return nil, fmt.Errorf("properties filter policy out of bounds")
+		}
+		p.FilterPolicy = string(data[off : off+nameLen])
+		off += nameLen
+	}
+
+	if off+4 <= len(data) {
+		p.IndexTopBlocks = binary.BigEndian.Uint32(data[off : off+4])
+		off += 4
+	}
+
+	if off+blockHandleSize <= len(data) {
+		p.BlockFilters.Decode(data[off : off+blockHandleSize])
+	}
+
+	return p, nil
+}
@@ -0,0 +1,198 @@
+package sst
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+)
+
+const (
+	ribbonWidth    = 64   // coefficient bits of a key, the width of the band
+	ribbonOverhead = 0.15 // extra slots per key so that banding rarely fails
+	ribbonAttempts = 16
+)
+
+type ribbonPolicy struct {
+	bitsPerKey int
+}
+
+// NewRibbonPolicy builds standard ribbon filters (Dillinger & Walzer). Every key
+// is an equation over a band of 64 slots, the filter stores a solution of the
+// system. Close to the optimal space for a given false positive rate, for
+// slower builds than bloom filters
+func NewRibbonPolicy(bitsPerKey int) FilterPolicy {
+	return &ribbonPolicy{bitsPerKey: bitsPerKey}
+}
+
+func (p *ribbonPolicy) Name() string {
+	return RibbonPolicyName
+}
+
+// resultBits is the number of bits stored per slot, false positive rate is 2^-r
+func (p *ribbonPolicy) resultBits() uint {
+	r := int(float64(p.bitsPerKey) / (1 + ribbonOverhead))
+	if r < 1 {
+		r = 1
+	}
+	if r > 32 {
+		r = 32
+	}
+	return uint(r)
+}
+
+// +------------------------------------+-------------+-----------------+-------------------+
+// | solution (r bits per slot, padded) | seed (4b)   |  # starts (4b)  |  result bits (1b) |
+// +------------------------------------+-------------+-----------------+-------------------+
+func (p *ribbonPolicy) Build(keys []types.Bytes) ([]byte, error) {
+	r := p.resultBits()
+
+	// banding fails with a small probability, retry with another seed and more room
+	for attempt := 0; attempt < ribbonAttempts; attempt += 1 {
+		overhead := ribbonOverhead + 0.05*float64(attempt)
+		f := &ribbon{
+			r:       r,
+			nStarts: uint32(float64(len(keys))*(1+overhead)) + 1,
+			seed:    uint32(attempt),
+		}
+		if f.solve(keys) {
+			data := f.data
+			data = binary.BigEndian.AppendUint32(data, f.seed)
+			data = binary.BigEndian.AppendUint32(data, f.nStarts)
+			data = append(data, byte(f.r))
+			return data, nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to build ribbon filter over %d keys", len(keys))
+}
+
+func (p *ribbonPolicy) Decode(data []byte) (Filter, error) {
+	if len(data) < 4+4+1 {
+		return nil, fmt.Errorf("ribbon filter too short")
+	}
+
+	trailer := data[len(data)-9:]
+	f := &ribbon{
+		seed:    binary.BigEndian.Uint32(trailer[0:4]),
+		nStarts: binary.BigEndian.Uint32(trailer[4:8]),
+		r:       uint(trailer[8]),
+		data:    data[:len(data)-9],
+	}
+
+	if f.r < 1 || f.r > 32 || f.nStarts == 0 || len(f.data) != f.packedSize() {
+		return nil, fmt.Errorf("ribbon filter size mismatch: %d bytes for %d starts", len(f.data), f.nStarts)
+	}
+
+	return f, nil
+}
+
+type ribbon struct {
+	data    []byte // solution packed as r bits per slot
+	r       uint
+	nStarts uint32
+	seed    uint32
+}
+
+func (f *ribbon) nSlots() int {
+	return int(f.nStarts) + ribbonWidth - 1
+}
+
+// packedSize pads the solution so that any slot can be read as a whole uint64
+func (f *ribbon) packedSize() int {
+	return (f.nSlots()*int(f.r)+7)/8 + 8
+}
+
+// equation returns the first slot, the coefficients and the expected result of key
+func (f *ribbon) equation(key types.Bytes) (int, uint64, uint32) {
+	h := mix64(hash64(key) ^ uint64(f.seed)*0x9e3779b97f4a7c15)
+
+	start := fastRange(uint32(h>>32), f.nStarts)
+	coeff := mix64(h+1) | 1 // first coefficient is always set, the row pivots on its start
+	result := uint32(mix64(h+2)) & f.mask()
+
+	return int(start), coeff, result
+}
+
+func (f *ribbon) mask() uint32 {
+	return uint32(1<<f.r - 1)
+}
+
+// solve bands the equations of every key (gaussian elimination on the fly)
+// then back substitutes a solution, returns false when the system has none
+func (f *ribbon) solve(keys []types.Bytes) bool {
+	n := f.nSlots()
+	coeffs := make([]uint64, n)
+	results := make([]uint32, n)
+
+	for _, k := range keys {
+		i, c, res := f.equation(k)
+		for {
+			if coeffs[i] == 0 {
+				coeffs[i] = c
+				results[i] = res
+				break
+			}
+
+			c ^= coeffs[i]
+			res ^= results[i]
+			if c == 0 {
+				if res != 0 {
+					return false
+				}
+				// same equation as another key
+				break
+			}
+
+			tz := bits.TrailingZeros64(c)
+			i += tz
+			c >>= tz
+		}
+	}
+
+	solution := make([]uint32, n)
+	for i := n - 1; i >= 0; i -= 1 {
+		c := coeffs[i]
+		if c == 0 {
+			// unconstrained, random values keep the false positive rate at 2^-r
+			solution[i] = uint32(mix64(uint64(i)^uint64(f.seed))) & f.mask()
+			continue
+		}
+
+		v := results[i]
+		for rest := c >> 1; rest != 0; rest &= rest - 1 {
+			v ^= solution[i+1+bits.TrailingZeros64(rest)]
+		}
+		solution[i] = v
+	}
+
+	f.data = make([]byte, f.packedSize())
+	for i, v := range solution {
+		f.set(i, v)
+	}
+	return true
+}
+
+func (f *ribbon) set(i int, v uint32) {
+	off := i * int(f.r)
+	w := binary.LittleEndian.Uint64(f.data[off/8:])
+	w |= uint64(v) << (off % 8)
+	binary.LittleEndian.PutUint64(f.data[off/8:], w)
+}
+
+func (f *ribbon) get(i int) uint32 {
+	off := i * int(f.r)
+	w := binary.LittleEndian.Uint64(f.data[off/8:])
+	return uint32(w>>(off%8)) & f.mask()
+}
+
+func (f *ribbon) MayContain(key types.Bytes) bool {
+	start, c, result := f.equation(key)
+
+	var acc uint32
+	for ; c != 0; c &= c - 1 {
+		acc ^= f.get(start + bits.TrailingZeros64(c))
+	}
+	return acc == result
+}
@@ -0,0 +1,48 @@
+package sst
+
+import (
+	"bytes"
+
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+	"github.com/ttn-nguyen42/go-mini-lsm/pkg/cache"
+)
+
+// RowKey identifies the value of a key in a table. Tables are never modified,
+// so a cached row stays valid until its table is removed and is never looked up again
+type RowKey struct {
+	SstId int32
+	Key   string
+}
+
+func (k RowKey) Size() int {
+	return 4 + len(k.Key)
+}
+
+func NewRowKey(sstId int32, key types.Bytes) RowKey {
+	return RowKey{SstId: sstId, Key: string(key)}
+}
+
+type Row struct {
+	Value types.Bytes
+}
+
+func (r *Row) Size() int {
+	return len(r.Value)
+}
+
+// NewRow copies value so that the row does not keep the block it was read from in memory
+func NewRow(value types.Bytes) *Row {
+	return &Row{Value: bytes.Clone(value)}
+}
+
+type RowCache cache.Cache[RowKey, *Row]
+
+// NewRowCache creates a cache holding up to size bytes of keys and values found in tables,
+// sharded as the block cache is
+func NewRowCache(size int, options ...cache.Option) RowCache {
+	n := 1
+	for n < maxBlockCacheShards && size/(n*2) >= minBlockCacheShard {
+		n *= 2
+	}
+	return cache.NewSharded[RowKey, *Row](size, n, options...)
+}
@@ -1,19 +1,14 @@
 package sst
 
 import (
-	"bytes"
-	"encoding/binary"
 	"fmt"
-	"hash/crc32"
-
-	"github.com/bits-and-blooms/bloom/v3"
 )
 
-// +-----------+-----------------+------------+-----------------+-------------------------+-------------------+--------------+--------------------+----------------+-----------------+
-// | block #0  |  checksum (4b)  |  block #1  |  checksum (4b)  |  # of met. blocks (4b)  |  metadata blocks  |  CRC32 (4b)  |  met. offset (4b)  |  bloom filter  |  bf offset (4b) |
-// +-----------+-----------------+------------+-----------------+-------------------------+-------------------+--------------+--------------------+----------------+-----------------+
-func Decode(id int32, f *FileObject, cache BlockCache) (*SortedTable, error) {
-	t, err := decodeTable(f)
+// +-----------+---------------+-----------------+-----+-----------------+-------------------------+-------------------+--------------+----------------+-----------------+--------------+--------------+-----------------+
+// | block #0  |  compr. (1b)  |  checksum (4b)  | ... |  checksum (4b)  |  # of met. blocks (4b)  |  metadata blocks  |  CRC32 (4b)  |  filter        |  block filters  |  dictionary  |  properties  |  footer (48b)   |
+// +-----------+---------------+-----------------+-----+-----------------+-------------------------+-------------------+--------------+----------------+-----------------+--------------+--------------+-----------------+
+func Decode(id int32, f *FileObject, cache BlockCache, options ...TableOption) (*SortedTable, error) {
+	t, err := decodeTable(f, getTableOpts(options...))
 	if err != nil {
 		return nil, err
 	}
@@ -22,82 +17,153 @@ func Decode(id int32, f *FileObject, cache BlockCache) (*SortedTable, error) {
 	return t, nil
 }
 
-func decodeTable(f *FileObject) (*SortedTable, error) {
+// decodeTable only reads the footer and the meta-blocks,
+// data blocks are read and checksummed when they are first accessed
+func decodeTable(f *FileObject, opts *TableOptions) (*SortedTable, error) {
 	size := f.Size()
+	if size < footerSize {
+		return nil, fmt.Errorf("%w: file too short", ErrNotTable)
+	}
 
-	buf := make([]byte, size)
+	// Read footer (last 48 bytes)
+	rawFooter := make([]byte, footerSize)
+	_, err := f.ReadAt(rawFooter, int64(size-footerSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read footer from file: %s", err)
+	}
+	ft, err := decodeFooter(rawFooter, size)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read properties
+	rawProps, err := readSection(f, ft.properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read properties: %s", err)
+	}
+	props, err := decodeProperties(rawProps)
+	if err != nil {
+		return nil, err
+	}
 
-	// Read bloom filter offset (last 4 bytes)
-	_, err := f.ReadAt(buf[size-4:], int64(size-4))
+	// Read dictionary, loaded once and shared by every block of the table
+	dict, err := readSection(f, ft.dict)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read bloom filter offset from file: %s", err)
+		return nil, fmt.Errorf("failed to read dictionary: %s", err)
 	}
-	blOffset := binary.BigEndian.Uint32(buf[size-4:])
-	size -= 4
 
-	// Read bloom filter
-	_, err = f.ReadAt(buf[blOffset:size], int64(blOffset))
+	// Read filter, with the policy it was built with
+	rawFilter, err := readSection(f, ft.filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read bloom filter from file: %s", err)
+		return nil, fmt.Errorf("failed to read filter from file: %s", err)
 	}
-	bf := &bloom.BloomFilter{}
-	if _, err = bf.ReadFrom(bytes.NewBuffer(buf[blOffset:size])); err != nil {
-		return nil, fmt.Errorf("failed to read bloom filter: %s", err)
+	filter, err := decodeFilter(props.FilterPolicy, rawFilter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read filter: %s", err)
+	}
+
+	var blockFilters []Filter
+	if props.BlockFilters.Size > 0 {
+		rawBlockFilters, err := readSection(f, props.BlockFilters)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read block filters from file: %s", err)
+		}
+		blockFilters, err = decodeBlockFilters(props.FilterPolicy, rawBlockFilters)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	t := &SortedTable{
+		file:         f,
+		firstKey:     props.FirstKey,
+		lastKey:      props.LastKey,
+		filter:       filter,
+		blockFilters: blockFilters,
+		dict:         dict,
+		props:        props,
 	}
-	size = int(blOffset)
 
-	// Read metadata blocks offset (4 bytes before bloom filter)
-	_, err = f.ReadAt(buf[size-4:size], int64(size-4))
+	if props.IndexPartitions > 0 {
+		t.index, err = decodePartitionedIndex(t, ft.index, props, opts)
+	} else {
+		t.index, err = decodeFlatIndex(f, ft)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to read metadata blocks offset: %s", err)
+		return nil, err
 	}
-	metOffset := binary.BigEndian.Uint32(buf[size-4 : size])
-	size -= 4
 
+	return t, nil
+}
+
+func decodeFlatIndex(f *FileObject, ft *footer) (*flatIndex, error) {
 	// Read metadata blocks
-	_, err = f.ReadAt(buf[metOffset:size], int64(metOffset))
+	rawIndex, err := readSection(f, ft.index)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read metadata blocks: %s", err)
 	}
-	metadata, err := decodeBlockMetadatas(buf[metOffset:size])
+	metadata, err := decodeBlockMetadatas(rawIndex)
 	if err != nil {
 		return nil, err
 	}
-	size = int(metOffset)
+	if len(metadata) == 0 {
+		return nil, fmt.Errorf("table has no blocks")
+	}
 
-	// Read data checksum (4 bytes before metadata blocks)
-	rawDataChecksum := buf[size-4 : size]
-	_, err = f.ReadAt(rawDataChecksum, int64(size-4))
-	if err != nil {
-		return nil, fmt.Errorf("failed to read data checksum: %s", err)
+	// Version 1 kept a checksum of all data blocks right before the metadata blocks
+	dataEnd := int(ft.index.Offset)
+	if ft.version == 1 {
+		dataEnd -= 4
 	}
+	for _, m := range metadata {
+		if int(m.Offset) >= dataEnd {
+			return nil, fmt.Errorf("block out of bounds: offset=%d", m.Offset)
+		}
+	}
+
+	return &flatIndex{blocks: metadata, dataEnd: dataEnd}, nil
+}
 
-	dataChecksum := binary.BigEndian.Uint32(rawDataChecksum)
-	size -= 4
+func decodePartitionedIndex(t *SortedTable, top BlockHandle, props *Properties, opts *TableOptions) (*partitionedIndex, error) {
+	idx := &partitionedIndex{table: t, top: top, topBlocks: int(props.IndexTopBlocks), nBlocks: int(props.NumBlocks)}
+	if !opts.PinTopLevelIndex {
+		return idx, nil
+	}
 
-	// Read data blocks
-	data := buf[:size]
-	_, err = f.ReadAt(data, 0)
+	blk, err := t.readBlock(top)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read data block: %s", err)
+		return nil, fmt.Errorf("failed to read top level index: %s", err)
+	}
+	entries, err := decodeIndexBlock(blk)
+	if err != nil {
+		return nil, err
+	}
+	if idx.topBlocks == 0 {
+		idx.pinned = entries
+		return idx, nil
 	}
 
-	// Verify checksum
-	calcChecksum := crc32.ChecksumIEEE(data)
-	if calcChecksum != dataChecksum {
-		return nil, fmt.Errorf("data checksum mismatch")
+	// entries index the blocks of the top level
+	idx.pinned = make(decodedEntries, 0, props.IndexPartitions)
+	for i, e := range entries {
+		blk, err := t.readBlock(e.Handle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read top level index block idx=%d: %s", i, err)
+		}
+		topEntries, err := decodeIndexBlock(blk)
+		if err != nil {
+			return nil, err
+		}
+		idx.pinned = append(idx.pinned, topEntries...)
 	}
 
-	// Construct SortedTable
-	fm := metadata[0]
-	lm := metadata[len(metadata)-1]
+	return idx, nil
+}
 
-	return &SortedTable{
-		file:            f,
-		firstKey:        fm.FirstKey,
-		lastKey:         lm.LastKey,
-		filter:          bf,
-		blocks:          metadata,
-		blockMetaOffset: int(metOffset - 4),
-	}, nil
+func readSection(f *FileObject, h BlockHandle) ([]byte, error) {
+	data := make([]byte, h.Size)
+	if _, err := f.ReadAt(data, int64(h.Offset)); err != nil {
+		return nil, err
+	}
+	return data, nil
 }
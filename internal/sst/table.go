@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"hash/crc32"
 
-	"github.com/bits-and-blooms/bloom/v3"
 	"github.com/ttn-nguyen42/go-mini-lsm/internal/block"
 	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
 )
@@ -14,13 +13,15 @@ import (
 var ErrClosed = fmt.Errorf("table closed")
 
 type SortedTable struct {
-	id              int32
-	firstKey        types.Bytes
-	lastKey         types.Bytes
-	filter          *bloom.BloomFilter
-	blocks          []BlockMeta
-	file            *FileObject
-	blockMetaOffset int
+	id           int32
+	firstKey     types.Bytes
+	lastKey      types.Bytes
+	filter       Filter
+	blockFilters []Filter // of every data block, nil when the table has none
+	index        blockIndex
+	file         *FileObject
+	dict         []byte
+	props        *Properties
 
 	cache BlockCache
 
@@ -40,6 +41,10 @@ func (s *SortedTable) Id() int32 {
 	return s.id
 }
 
+func (s *SortedTable) Properties() Properties {
+	return *s.props
+}
+
 func (s *SortedTable) FirstKey() types.Bytes {
 	return s.firstKey
 }
@@ -62,44 +67,141 @@ func (s *SortedTable) Contains(key types.Bytes) bool {
 		return true
 	}
 
-	return s.filter.Test(key)
+	return s.filter.MayContain(key)
+}
+
+// Get looks key up without building an iterator: the filter, the index, the
+// filter of the block then the block, probed through its hash index when it has one
+func (s *SortedTable) Get(key types.Bytes) (types.Bytes, bool, error) {
+	if !s.Contains(key) {
+		return nil, false, nil
+	}
+
+	idx, err := s.findBlock(key)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to find block: %s", err)
+	}
+	if !s.blockMayContain(idx, key) {
+		return nil, false, nil
+	}
+
+	blk, ok, err := s.Block(idx)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	return blk.Get(key)
+}
+
+// MultiGet looks sorted keys up, every block is fetched once for all the keys it may hold
+func (s *SortedTable) MultiGet(keys []types.Bytes) ([]types.Bytes, []bool, error) {
+	vals := make([]types.Bytes, len(keys))
+	found := make([]bool, len(keys))
+
+	var blk *block.Block
+	blkIdx := -1
+	for i, key := range keys {
+		if !s.Contains(key) {
+			continue
+		}
+
+		idx, err := s.findBlock(key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to find block: %s", err)
+		}
+		if !s.blockMayContain(idx, key) {
+			continue
+		}
+		if idx != blkIdx {
+			var ok bool
+			blk, ok, err = s.Block(idx)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !ok {
+				break
+			}
+			blkIdx = idx
+		}
+
+		vals[i], found[i], err = blk.Get(key)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return vals, found, nil
+}
+
+// blockMayContain returns false when the filter of the block at idx rules key out
+func (s *SortedTable) blockMayContain(idx int, key types.Bytes) bool {
+	if idx >= len(s.blockFilters) {
+		return true
+	}
+	return s.blockFilters[idx].MayContain(key)
 }
 
 func (s *SortedTable) Block(idx int) (*block.Block, bool, error) {
+	return s.block(idx, true)
+}
+
+// block returns the block at idx, without adding it to the cache unless fill is set
+func (s *SortedTable) block(idx int, fill bool) (*block.Block, bool, error) {
 	if s.closed {
 		return nil, false, ErrClosed
 	}
 
-	if idx >= len(s.blocks) {
+	if idx >= s.index.numBlocks() {
 		return nil, false, nil
 	}
 
-	var err error
-	key := CacheKey{SstId: s.id, BlockId: int32(idx)}
-	blk, _ := s.cache.GetOrSet(key, func() (*block.Block, bool) {
-		var blk *block.Block
-		blk, err = s.readBlock(idx)
+	h, err := s.index.handle(idx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	key := CacheKey{SstId: s.id, BlockId: int32(idx), Kind: DataBlock}
+	if !fill {
+		if blk, ok := s.cache.Get(key); ok {
+			return blk, true, nil
+		}
+		blk, err := s.readBlock(h)
 		if err != nil {
-			panic("failed to read block: " + err.Error())
+			return nil, false, err
 		}
-		return blk, true
-	})
+		return blk, true, nil
+	}
+
+	blk, err := s.cachedBlock(key, h)
+	if err != nil {
+		return nil, false, err
+	}
 
 	return blk, true, nil
 }
 
-func (s *SortedTable) readBlock(idx int) (*block.Block, error) {
-	end := s.blockMetaOffset - 4 // without the data checksum
-	if idx+1 < len(s.blocks) {
-		end = int(s.blocks[idx+1].Offset)
-	}
+// findBlock returns the index of the first block that may contain keys >= key
+func (s *SortedTable) findBlock(key types.Bytes) (int, error) {
+	return s.index.find(key)
+}
 
-	start := int(s.blocks[idx].Offset)
-	data := make([]byte, end-start)
+func (s *SortedTable) cachedBlock(key CacheKey, h BlockHandle) (*block.Block, error) {
+	blk, _, err := s.cache.GetOrSet(key, func() (*block.Block, error) {
+		return s.readBlock(h)
+	})
+
+	return blk, err
+}
 
-	_, err := s.file.ReadAt(data, int64(start))
+func (s *SortedTable) readBlock(h BlockHandle) (*block.Block, error) {
+	if h.Size < 5 {
+		return nil, fmt.Errorf("block at offset=%d too short: %d bytes", h.Offset, h.Size)
+	}
+	data := make([]byte, h.Size)
+
+	_, err := s.file.ReadAt(data, int64(h.Offset))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read block idx=%d: %s", idx, err)
+		return nil, fmt.Errorf("failed to read block at offset=%d: %s", h.Offset, err)
 	}
 
 	fileChecksum := binary.BigEndian.Uint32(data[len(data)-4:])
@@ -111,23 +213,33 @@ func (s *SortedTable) readBlock(idx int) (*block.Block, error) {
 		return nil, fmt.Errorf("block checksum mismatch")
 	}
 
+	flags := data[len(data)-1]
+	compression := CompressionType(flags &^ blockHashIndexFlag)
+	data, err = decompressBlock(compression, data[:len(data)-1], s.dict)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress block at offset=%d: %s", h.Offset, err)
+	}
+
+	if flags&blockHashIndexFlag != 0 {
+		return block.DecodeWithHashIndex(data)
+	}
 	return block.Decode(data)
 }
 
 func (s *SortedTable) NumBlocks() int {
-	return len(s.blocks)
+	return s.index.numBlocks()
 }
 
 func (s *SortedTable) IsClosed() bool {
 	return s.closed
 }
 
-func (s *SortedTable) Scan() (types.SeekableIterator, error) {
+func (s *SortedTable) Scan(options ...ReadOption) (types.SeekableIterator, error) {
 	if s.closed {
 		return nil, ErrClosed
 	}
 
-	return newIter(s), nil
+	return newIter(s, getReadOpts(options...)), nil
 }
 
 func (s *SortedTable) OverlapKeyRange(lower types.Bound[types.Bytes], upper types.Bound[types.Bytes]) bool {
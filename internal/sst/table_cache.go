@@ -0,0 +1,160 @@
+package sst
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// TableCache bounds the number of open table files. Tables keep their parsed
+// metadata (index, filter, properties) in memory while their file is opened on
+// demand and closed again when it is the least recently used above the limit
+type TableCache struct {
+	lock    sync.Mutex
+	maxOpen int
+	lru     *list.List // of *openFile, front is the most recently used
+	files   map[string]*openFile
+}
+
+type openFile struct {
+	path      string
+	f         *os.File
+	err       error         // set when opening the file failed
+	ready     chan struct{} // closed once the file is opened, or failed to
+	refs      int           // reads in progress, the file is not closed until they are done
+	forgotten bool
+	elem      *list.Element
+}
+
+func NewTableCache(maxOpenFiles int) *TableCache {
+	if maxOpenFiles < 1 {
+		maxOpenFiles = 1
+	}
+	return &TableCache{
+		maxOpen: maxOpenFiles,
+		lru:     list.New(),
+		files:   make(map[string]*openFile),
+	}
+}
+
+// OpenFiles returns the number of table files currently open
+func (c *TableCache) OpenFiles() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+// acquire returns the open file at path, it must be released after use.
+// Files are opened and closed without holding the lock, reads of a file
+// being opened wait for it
+func (c *TableCache) acquire(path string) (*openFile, error) {
+	c.lock.Lock()
+	if of, ok := c.files[path]; ok {
+		of.refs += 1
+		c.lru.MoveToFront(of.elem)
+		c.lock.Unlock()
+
+		<-of.ready
+		if of.err != nil {
+			c.release(of)
+			return nil, of.err
+		}
+		return of, nil
+	}
+
+	of := &openFile{path: path, refs: 1, ready: make(chan struct{})}
+	of.elem = c.lru.PushFront(of)
+	c.files[path] = of
+	c.lock.Unlock()
+
+	f, err := os.Open(path)
+
+	c.lock.Lock()
+	if err != nil {
+		// the next read opens the file again
+		of.err = fmt.Errorf("failed to open table file: %s", err)
+		of.refs -= 1
+		if c.files[path] == of {
+			c.remove(of)
+		}
+		close(of.ready)
+		c.lock.Unlock()
+		return nil, of.err
+	}
+	of.f = f
+	close(of.ready)
+	evicted := c.evict()
+	c.lock.Unlock()
+
+	closeFiles(evicted)
+	return of, nil
+}
+
+// evict drops the least recently used files not being read, the limit can
+// be exceeded while more files than allowed are read at the same time.
+// The dropped files are closed by the caller once the lock is released
+func (c *TableCache) evict() []*os.File {
+	var evicted []*os.File
+	for e := c.lru.Back(); e != nil && c.lru.Len() > c.maxOpen; {
+		of := e.Value.(*openFile)
+		e = e.Prev()
+		if of.refs > 0 {
+			continue
+		}
+		c.remove(of)
+		evicted = append(evicted, of.f)
+	}
+	return evicted
+}
+
+func (c *TableCache) remove(of *openFile) {
+	c.lru.Remove(of.elem)
+	delete(c.files, of.path)
+}
+
+func (c *TableCache) release(of *openFile) {
+	c.lock.Lock()
+	of.refs -= 1
+	if of.refs > 0 {
+		c.lock.Unlock()
+		return
+	}
+
+	var closing []*os.File
+	if of.forgotten {
+		if of.f != nil {
+			closing = append(closing, of.f)
+		}
+	} else {
+		closing = c.evict()
+	}
+	c.lock.Unlock()
+
+	closeFiles(closing)
+}
+
+// forget closes the file at path once the reads in progress are done
+func (c *TableCache) forget(path string) error {
+	c.lock.Lock()
+	of, ok := c.files[path]
+	if !ok {
+		c.lock.Unlock()
+		return nil
+	}
+	c.remove(of)
+	if of.refs > 0 {
+		of.forgotten = true
+		c.lock.Unlock()
+		return nil
+	}
+	c.lock.Unlock()
+
+	return of.f.Close()
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
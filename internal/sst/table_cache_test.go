@@ -0,0 +1,117 @@
+package sst_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/sst"
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+)
+
+func TestTableCacheBoundsOpenFiles(t *testing.T) {
+	dir := t.TempDir()
+	blockCache := sst.NewBlockCache(0) // every read goes to the file
+	tableCache := sst.NewTableCache(2)
+
+	var tables []*sst.SortedTable
+	for i := range 5 {
+		b := sst.NewBuilder(128, sst.WithTableCache(tableCache))
+		for j := range 20 {
+			key := types.Bytes(fmt.Sprintf("t%d-key%02d", i, j))
+			val := types.Bytes(fmt.Sprintf("t%d-val%02d", i, j))
+			assert.NoError(t, b.Add(key, val))
+		}
+		table, err := b.Build(int32(i), filepath.Join(dir, fmt.Sprintf("%d.sst", i)), blockCache)
+		assert.NoError(t, err)
+		tables = append(tables, table)
+	}
+	assert.Equal(t, 0, tableCache.OpenFiles())
+
+	for round := range 2 {
+		for i, table := range tables {
+			for j := range 20 {
+				val, ok, err := table.Get(types.Bytes(fmt.Sprintf("t%d-key%02d", i, j)))
+				assert.NoError(t, err)
+				assert.True(t, ok, "round %d table %d key %d", round, i, j)
+				assert.Equal(t, fmt.Sprintf("t%d-val%02d", i, j), string(val))
+			}
+			assert.LessOrEqual(t, tableCache.OpenFiles(), 2)
+		}
+	}
+
+	for _, table := range tables {
+		assert.NoError(t, table.Close())
+	}
+	assert.Equal(t, 0, tableCache.OpenFiles())
+
+	// a table opened through the cache reads the same data
+	fo, err := sst.ReadCached(filepath.Join(dir, "3.sst"), nil, tableCache)
+	assert.NoError(t, err)
+	decoded, err := sst.Decode(3, fo, blockCache)
+	assert.NoError(t, err)
+	defer decoded.Close()
+	val, ok, err := decoded.Get(types.Bytes("t3-key07"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "t3-val07", string(val))
+	assert.Equal(t, 1, tableCache.OpenFiles())
+}
+
+func TestTableCacheConcurrentReads(t *testing.T) {
+	dir := t.TempDir()
+	blockCache := sst.NewBlockCache(0)
+	tableCache := sst.NewTableCache(2)
+
+	var tables []*sst.SortedTable
+	for i := range 4 {
+		b := sst.NewBuilder(128, sst.WithTableCache(tableCache))
+		for j := range 20 {
+			assert.NoError(t, b.Add(types.Bytes(fmt.Sprintf("key%02d", j)), types.Bytes(fmt.Sprintf("t%d-val%02d", i, j))))
+		}
+		table, err := b.Build(int32(i), filepath.Join(dir, fmt.Sprintf("%d.sst", i)), blockCache)
+		assert.NoError(t, err)
+		defer table.Close()
+		tables = append(tables, table)
+	}
+
+	wg := sync.WaitGroup{}
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 20 {
+				i := (g + j) % len(tables)
+				val, ok, err := tables[i].Get(types.Bytes(fmt.Sprintf("key%02d", j)))
+				assert.NoError(t, err)
+				assert.True(t, ok)
+				assert.Equal(t, fmt.Sprintf("t%d-val%02d", i, j), string(val))
+			}
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, tableCache.OpenFiles(), 2)
+}
+
+func TestTableCacheOpenFailure(t *testing.T) {
+	tableCache := sst.NewTableCache(2)
+
+	path := filepath.Join(t.TempDir(), "missing.sst")
+	_, err := sst.ReadCached(path, nil, tableCache)
+	assert.Error(t, err)
+	assert.Equal(t, 0, tableCache.OpenFiles())
+
+	// the failed open is not kept, the file is opened again once it exists
+	b := sst.NewBuilder(128)
+	assert.NoError(t, b.Add(types.Bytes("key"), types.Bytes("val")))
+	table, err := b.Build(1, path, sst.NewBlockCache(0))
+	assert.NoError(t, err)
+	assert.NoError(t, table.Close())
+
+	fo, err := sst.ReadCached(path, nil, tableCache)
+	assert.NoError(t, err)
+	assert.NoError(t, fo.Close())
+	assert.Equal(t, 0, tableCache.OpenFiles())
+}
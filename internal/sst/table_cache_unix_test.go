@@ -0,0 +1,71 @@
+//go:build unix
+
+package sst_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/sst"
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+)
+
+func TestTableCacheOpensOutsideLock(t *testing.T) {
+	dir := t.TempDir()
+	blockCache := sst.NewBlockCache(0) // every read goes to the file
+	tableCache := sst.NewTableCache(1)
+
+	b := sst.NewBuilder(128, sst.WithTableCache(tableCache))
+	for i := range 20 {
+		assert.NoError(t, b.Add(types.Bytes(fmt.Sprintf("key%02d", i)), types.Bytes(fmt.Sprintf("val%02d", i))))
+	}
+	table, err := b.Build(1, filepath.Join(dir, "1.sst"), blockCache)
+	assert.NoError(t, err)
+	defer table.Close()
+
+	// opening a fifo for reading blocks until a writer opens it
+	fifo := filepath.Join(dir, "slow.sst")
+	assert.NoError(t, syscall.Mkfifo(fifo, 0o600))
+
+	opened := make(chan struct{})
+	go func() {
+		defer close(opened)
+		fo, err := sst.ReadCached(fifo, nil, tableCache)
+		if err == nil {
+			fo.Close()
+		}
+	}()
+
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		// the fifo is being opened once it is counted
+		deadline := time.Now().Add(5 * time.Second)
+		for tableCache.OpenFiles() == 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		for i := range 20 {
+			val, ok, err := table.Get(types.Bytes(fmt.Sprintf("key%02d", i)))
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, fmt.Sprintf("val%02d", i), string(val))
+		}
+	}()
+
+	select {
+	case <-read:
+	case <-time.After(5 * time.Second):
+		t.Error("reads waited for another file to open")
+	}
+
+	w, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+	assert.NoError(t, err)
+	w.Close()
+	<-opened
+	<-read
+}
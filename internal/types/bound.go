@@ -1,16 +1,29 @@
 package types
 
 type Bound[T any] struct {
-	data     T
-	included bool
+	data      T
+	included  bool
+	unbounded bool
 }
 
 func (b Bound[T]) Data() T {
 	return b.data
 }
 
+func (b Bound[T]) IsIncluded() bool {
+	return b.included
+}
+
+// IsUnbounded returns true if the bound does not limit the range on its side
+func (b Bound[T]) IsUnbounded() bool {
+	return b.unbounded
+}
+
 // IsBefore returns true if data is in the left side of the bound
 func (b Bound[T]) IsBefore(data T, cmp Comparator[T]) bool {
+	if b.unbounded {
+		return false
+	}
 	c := cmp(data, b.data)
 	if b.included {
 		return c < 0
@@ -21,6 +34,9 @@ func (b Bound[T]) IsBefore(data T, cmp Comparator[T]) bool {
 
 // IsAfter returns true if data is in the right side of the bound
 func (b Bound[T]) IsAfter(data T, cmp Comparator[T]) bool {
+	if b.unbounded {
+		return false
+	}
 	c := cmp(data, b.data)
 	if b.included {
 		return c > 0
@@ -43,31 +59,40 @@ func Exclude[T any](data T) Bound[T] {
 	}
 }
 
-type Comparator[T any] func(a, b T) int
+// Unbounded returns a bound that includes everything on its side, as lower or upper bound
+func Unbounded[T any]() Bound[T] {
+	return Bound[T]{
+		unbounded: true,
+	}
+}
 
-func IsWithinBoundary[T any](l Bound[T], r Bound[T], data T, compare Comparator[T]) bool {
-	compareLower := compare(data, l.data)
-	if l.included {
-		if compareLower < 0 {
-			return false
-		}
-	} else {
-		if compareLower <= 0 {
-			return false
-		}
+// PrefixRange returns the bounds of the keys starting with prefix. The upper bound
+// is the smallest key after all of them, excluded, or unbounded when there is none
+func PrefixRange(prefix Bytes) (Bound[Bytes], Bound[Bytes]) {
+	succ := prefixSuccessor(prefix)
+	if succ == nil {
+		return Include(prefix), Unbounded[Bytes]()
 	}
-	compareUpper := compare(data, r.data)
-	if r.included {
-		if compareUpper > 0 {
-			return false
-		}
-	} else {
-		if compareUpper >= 0 {
-			return false
+	return Include(prefix), Exclude(succ)
+}
+
+// prefixSuccessor returns the smallest key greater than every key starting with prefix,
+// nil when prefix only has 0xff bytes
+func prefixSuccessor(prefix Bytes) Bytes {
+	succ := append(Bytes(nil), prefix...)
+	for i := len(succ) - 1; i >= 0; i -= 1 {
+		if succ[i] < 0xff {
+			succ[i] += 1
+			return succ[:i+1]
 		}
 	}
+	return nil
+}
 
-	return true
+type Comparator[T any] func(a, b T) int
+
+func IsWithinBoundary[T any](l Bound[T], r Bound[T], data T, compare Comparator[T]) bool {
+	return !l.IsBefore(data, compare) && !r.IsAfter(data, compare)
 }
 
 func IsWithinRange[T any](l T, r T, data T, compare Comparator[T]) bool {
@@ -78,15 +103,14 @@ func IsWithinRange[T any](l T, r T, data T, compare Comparator[T]) bool {
 }
 
 func AreBoundariesOverlap[T any](l1 Bound[T], r1 Bound[T], l2 Bound[T], r2 Bound[T], cmp Comparator[T]) bool {
-	// If l1 > r2, intervals do not overlap
-	cmpl1r2 := cmp(l1.data, r2.data)
-	if cmpl1r2 > 0 || (cmpl1r2 == 0 && (!l1.included || !r2.included)) {
-		return false
-	}
-	// If l2 > r1, intervals do not overlap
-	cmpl2r1 := cmp(l2.data, r1.data)
-	if cmpl2r1 > 0 || (cmpl2r1 == 0 && (!l2.included || !r1.included)) {
+	return !isAfterBound(l1, r2, cmp) && !isAfterBound(l2, r1, cmp)
+}
+
+// isAfterBound returns true if the lower bound l starts after the upper bound r ends
+func isAfterBound[T any](l Bound[T], r Bound[T], cmp Comparator[T]) bool {
+	if l.unbounded || r.unbounded {
 		return false
 	}
-	return true
+	c := cmp(l.data, r.data)
+	return c > 0 || (c == 0 && (!l.included || !r.included))
 }
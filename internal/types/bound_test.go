@@ -113,3 +113,46 @@ func TestAreBoundariesOverlap(t *testing.T) {
 	l2, r2 = Exclude(1), Exclude(5)
 	assert.False(t, AreBoundariesOverlap(l1, r1, l2, r2, cmp))
 }
+
+func TestUnbounded(t *testing.T) {
+	cmp := func(a, b int) int { return a - b }
+	u := Unbounded[int]()
+
+	assert.True(t, u.IsUnbounded())
+	assert.False(t, u.IsBefore(-100, cmp))
+	assert.False(t, u.IsAfter(100, cmp))
+
+	// (-inf, 10]
+	assert.True(t, IsWithinBoundary(u, Include(10), -100, cmp))
+	assert.False(t, IsWithinBoundary(u, Include(10), 11, cmp))
+	// [1, +inf)
+	assert.True(t, IsWithinBoundary(Include(1), u, 100, cmp))
+	assert.False(t, IsWithinBoundary(Include(1), u, 0, cmp))
+	assert.True(t, IsWithinBoundary(u, u, 0, cmp))
+
+	// (-inf, 0) and [1, +inf) => no overlap
+	assert.False(t, AreBoundariesOverlap(u, Exclude(0), Include(1), u, cmp))
+	// (-inf, +inf) and [1, 5] => overlap
+	assert.True(t, AreBoundariesOverlap(u, u, Include(1), Include(5), cmp))
+	// [6, +inf) and [1, 5] => no overlap
+	assert.False(t, AreBoundariesOverlap(Include(6), u, Include(1), Include(5), cmp))
+	// [1, +inf) and [5, +inf) => overlap
+	assert.True(t, AreBoundariesOverlap(Include(1), u, Include(5), u, cmp))
+}
+
+func TestPrefixRange(t *testing.T) {
+	lower, upper := PrefixRange(Bytes("ab"))
+	assert.Equal(t, Include(Bytes("ab")), lower)
+	assert.Equal(t, Exclude(Bytes("ac")), upper)
+	assert.True(t, IsWithinBoundary(lower, upper, Bytes("ab\xff\xff"), BytesComparator))
+	assert.False(t, IsWithinBoundary(lower, upper, Bytes("ac"), BytesComparator))
+	assert.False(t, IsWithinBoundary(lower, upper, Bytes("a"), BytesComparator))
+
+	// trailing 0xff bytes are dropped
+	_, upper = PrefixRange(Bytes("a\xff"))
+	assert.Equal(t, Exclude(Bytes("b")), upper)
+
+	// nothing sorts after keys of 0xff bytes
+	_, upper = PrefixRange(Bytes("\xff\xff"))
+	assert.True(t, upper.IsUnbounded())
+}
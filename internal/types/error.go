@@ -2,4 +2,7 @@ package types
 
 import "fmt"
 
-var ErrIterEnd error = fmt.Errorf("iterator ended")
\ No newline at end of file
+var ErrIterEnd error = fmt.Errorf("iterator ended")
+
+// ErrNotReversible is returned when moving backward over iterators that only move forward
+var ErrNotReversible error = fmt.Errorf("iterator cannot move backward")
@@ -7,13 +7,24 @@ type Iterator interface {
 	Next() error
 }
 
-type ClosableIterator interface {
+// ReverseIterator also moves backward. HasNext tells whether the iterator is on
+// an entry in either direction, Prev before the first entry leaves it on none
+type ReverseIterator interface {
 	Iterator
+	Prev() error
+	SeekToLast() error
+	// SeekToKey moves to the first entry >= key
+	SeekToKey(key Bytes) error
+	// SeekForPrev moves to the last entry <= key
+	SeekForPrev(key Bytes) error
+}
+
+type ClosableIterator interface {
+	ReverseIterator
 	Close()
 }
 
 type SeekableIterator interface {
-	Iterator
+	ReverseIterator
 	Seek(idx int) error
-	SeekToKey(key Bytes) error
 }
@@ -1,7 +1,7 @@
 package types
 
 import (
-	"fmt"
+	"errors"
 
 	"github.com/ttn-nguyen42/go-mini-lsm/pkg/heap"
 )
@@ -25,34 +25,63 @@ var compareHeapWrapper heap.Comparator[*heapWrapper] = func(a, b *heapWrapper) i
 	}
 }
 
+// compareHeapWrapperReverse puts the largest key first, the latest iterator first on equal keys
+var compareHeapWrapperReverse heap.Comparator[*heapWrapper] = func(a, b *heapWrapper) int {
+	cmp := BytesComparator(b.iter.Key(), a.iter.Key())
+	if cmp != 0 {
+		return cmp
+	}
+
+	if a.id < b.id {
+		return -1
+	} else {
+		return 1
+	}
+}
+
 type mergeIter struct {
-	heap heap.Heap[*heapWrapper]
-	cur  *heapWrapper
+	iters   []*heapWrapper // every iterator, also those without entries left
+	heap    heap.Heap[*heapWrapper]
+	cur     *heapWrapper
+	reverse bool
 }
 
-func NewMergeIter(iters ...Iterator) Iterator {
-	if len(iters) == 0 {
-		return &mergeIter{heap: heap.MinHeap(compareHeapWrapper), cur: nil}
+// NewMergeIter merges sorted iterators, the first one wins on equal keys.
+// Moving backward returns ErrNotReversible unless all of them are ReverseIterators
+func NewMergeIter(iters ...Iterator) ReverseIterator {
+	wrappers := make([]*heapWrapper, 0, len(iters))
+	for idx, iter := range iters {
+		wrappers = append(wrappers, &heapWrapper{
+			id:   idx,
+			iter: iter,
+		})
+	}
+
+	m := &mergeIter{iters: wrappers}
+	m.rebuild()
+	return m
+}
+
+// rebuild orders the iterators with entries left for the current direction
+func (m *mergeIter) rebuild() {
+	cmp := compareHeapWrapper
+	if m.reverse {
+		cmp = compareHeapWrapperReverse
 	}
 
 	// Only include iterators that have items
-	validWrappers := make([]*heapWrapper, 0, len(iters))
-	for idx, iter := range iters {
-		if iter.HasNext() {
-			validWrappers = append(validWrappers, &heapWrapper{
-				id:   idx,
-				iter: iter,
-			})
+	validWrappers := make([]*heapWrapper, 0, len(m.iters))
+	for _, w := range m.iters {
+		if w.iter.HasNext() {
+			validWrappers = append(validWrappers, w)
 		}
 	}
 
-	if len(validWrappers) == 0 {
-		return &mergeIter{heap: heap.MinHeap(compareHeapWrapper), cur: nil}
+	m.heap = heap.MinHeapSeeded(cmp, validWrappers)
+	m.cur = nil
+	if m.heap.Len() > 0 {
+		m.cur = m.heap.Pop()
 	}
-
-	h := heap.MinHeapSeeded(compareHeapWrapper, validWrappers)
-	cur := h.Pop()
-	return &mergeIter{heap: h, cur: cur}
 }
 
 func (m *mergeIter) HasNext() bool {
@@ -71,55 +100,133 @@ func (m *mergeIter) Key() Bytes {
 	return m.cur.iter.Key()
 }
 
+func (m *mergeIter) Value() Bytes {
+	if m.cur == nil {
+		panic("iterator has ended")
+	}
+
+	return m.cur.iter.Value()
+}
+
 func (m *mergeIter) Next() error {
-	cur := m.cur
+	if !m.HasNext() {
+		return ErrIterEnd
+	}
+	if m.reverse {
+		return m.turn(false)
+	}
 
-	for m.heap.Len() > 0 {
-		top := m.heap.Peek()
+	return m.step(func(it Iterator) error {
+		return it.Next()
+	})
+}
 
-		if BytesComparator(cur.iter.Key(), top.iter.Key()) == 0 {
-			if err := top.iter.Next(); err != nil {
-				m.heap.Pop()
-				continue
-			}
+func (m *mergeIter) Prev() error {
+	if !m.HasNext() {
+		return ErrIterEnd
+	}
+	if !m.reverse {
+		return m.turn(true)
+	}
 
-			if !top.iter.HasNext() {
-				m.heap.Pop()
-				continue
-			}
-		} else {
-			break
+	return m.step(func(it Iterator) error {
+		return it.(ReverseIterator).Prev()
+	})
+}
+
+// step moves past the current key in the direction of the heap
+func (m *mergeIter) step(move func(Iterator) error) error {
+	cur := m.cur
+
+	// older iterators holding the same key move past it too
+	for m.heap.Len() > 0 && BytesComparator(m.heap.Peek().iter.Key(), cur.iter.Key()) == 0 {
+		top := m.heap.Pop()
+		if err := move(top.iter); err != nil && !errors.Is(err, ErrIterEnd) {
+			return err
+		}
+		if top.iter.HasNext() {
+			m.heap.Push(top)
 		}
 	}
 
-	if err := cur.iter.Next(); err != nil {
+	if err := move(cur.iter); err != nil && !errors.Is(err, ErrIterEnd) {
 		return err
 	}
+	if cur.iter.HasNext() {
+		m.heap.Push(cur)
+	}
+
+	if m.heap.Len() <= 0 {
+		m.cur = nil
+		return ErrIterEnd
+	}
+	m.cur = m.heap.Pop()
+	return nil
+}
+
+// turn changes direction, every iterator moves past the current key
+func (m *mergeIter) turn(reverse bool) error {
+	key := m.cur.iter.Key()
 
-	if !cur.iter.HasNext() {
-		if m.heap.Len() <= 0 {
-			return ErrIterEnd
+	err := m.seekAll(func(it ReverseIterator) error {
+		if reverse {
+			if err := it.SeekForPrev(key); err != nil {
+				return err
+			}
+			if it.HasNext() && BytesComparator(it.Key(), key) == 0 {
+				return it.Prev()
+			}
+			return nil
+		}
+
+		if err := it.SeekToKey(key); err != nil {
+			return err
+		}
+		if it.HasNext() && BytesComparator(it.Key(), key) == 0 {
+			return it.Next()
 		}
-		m.cur = m.heap.Pop()
 		return nil
+	}, reverse)
+	if err != nil {
+		return err
 	}
 
-	// Compare with heap top and swap if necessary
-	if m.heap.Len() > 0 {
-		top := m.heap.Peek()
-		if compareHeapWrapper(m.cur, top) > 0 {
-			fmt.Println("SWAP", m.cur.iter.Key(), top.iter.Key())
-			m.heap.Push(m.cur)
-			m.cur = m.heap.Pop()
-		}
+	if m.cur == nil {
+		return ErrIterEnd
 	}
 	return nil
 }
 
-func (m *mergeIter) Value() Bytes {
-	if m.cur == nil {
-		panic("iterator has ended")
+func (m *mergeIter) seekAll(seek func(ReverseIterator) error, reverse bool) error {
+	for _, w := range m.iters {
+		it, ok := w.iter.(ReverseIterator)
+		if !ok {
+			return ErrNotReversible
+		}
+		if err := seek(it); err != nil && !errors.Is(err, ErrIterEnd) {
+			return err
+		}
 	}
 
-	return m.cur.iter.Value()
+	m.reverse = reverse
+	m.rebuild()
+	return nil
+}
+
+func (m *mergeIter) SeekToLast() error {
+	return m.seekAll(func(it ReverseIterator) error {
+		return it.SeekToLast()
+	}, true)
+}
+
+func (m *mergeIter) SeekToKey(key Bytes) error {
+	return m.seekAll(func(it ReverseIterator) error {
+		return it.SeekToKey(key)
+	}, false)
+}
+
+func (m *mergeIter) SeekForPrev(key Bytes) error {
+	return m.seekAll(func(it ReverseIterator) error {
+		return it.SeekForPrev(key)
+	}, true)
 }
@@ -77,3 +77,83 @@ func TestMergeIter_Empty(t *testing.T) {
 	merge := types.NewMergeIter()
 	assert.False(t, merge.HasNext())
 }
+
+type mockReverseIter struct {
+	mockIter
+}
+
+func (m *mockReverseIter) HasNext() bool {
+	return m.idx >= 0 && m.idx < len(m.keys)
+}
+
+func (m *mockReverseIter) Prev() error {
+	m.idx -= 1
+	return nil
+}
+
+func (m *mockReverseIter) SeekToLast() error {
+	m.idx = len(m.keys) - 1
+	return nil
+}
+
+func (m *mockReverseIter) SeekToKey(key types.Bytes) error {
+	m.idx = 0
+	for m.idx < len(m.keys) && types.BytesComparator(m.keys[m.idx], key) < 0 {
+		m.idx += 1
+	}
+	return nil
+}
+
+func (m *mockReverseIter) SeekForPrev(key types.Bytes) error {
+	m.idx = len(m.keys) - 1
+	for m.idx >= 0 && types.BytesComparator(m.keys[m.idx], key) > 0 {
+		m.idx -= 1
+	}
+	return nil
+}
+
+func TestMergeIter_Reverse(t *testing.T) {
+	it1 := &mockReverseIter{mockIter{
+		keys: []types.Bytes{types.Bytes("a"), types.Bytes("b"), types.Bytes("c")},
+		vals: []types.Bytes{types.Bytes("A1"), types.Bytes("B1"), types.Bytes("C1")},
+	}}
+	it2 := &mockReverseIter{mockIter{
+		keys: []types.Bytes{types.Bytes("b"), types.Bytes("c"), types.Bytes("d")},
+		vals: []types.Bytes{types.Bytes("B2"), types.Bytes("C2"), types.Bytes("D2")},
+	}}
+	merge := types.NewMergeIter(it1, it2)
+
+	assert.NoError(t, merge.SeekToLast())
+	var keys, vals []string
+	for merge.HasNext() {
+		keys = append(keys, string(merge.Key()))
+		vals = append(vals, string(merge.Value()))
+		merge.Prev()
+	}
+	assert.Equal(t, []string{"d", "c", "b", "a"}, keys)
+	assert.Equal(t, []string{"D2", "C1", "B1", "A1"}, vals)
+
+	// changing direction
+	assert.NoError(t, merge.SeekForPrev(types.Bytes("bb")))
+	assert.Equal(t, "B1", string(merge.Value()))
+	assert.NoError(t, merge.Next())
+	assert.Equal(t, "C1", string(merge.Value()))
+	assert.NoError(t, merge.Next())
+	assert.Equal(t, "D2", string(merge.Value()))
+	assert.NoError(t, merge.Prev())
+	assert.Equal(t, "C1", string(merge.Value()))
+	assert.NoError(t, merge.Prev())
+	assert.Equal(t, "B1", string(merge.Value()))
+
+	assert.NoError(t, merge.SeekToKey(types.Bytes("c")))
+	assert.Equal(t, "C1", string(merge.Value()))
+}
+
+func TestMergeIter_NotReversible(t *testing.T) {
+	it := &mockIter{
+		keys: []types.Bytes{types.Bytes("a")},
+		vals: []types.Bytes{types.Bytes("A")},
+	}
+	merge := types.NewMergeIter(it)
+	assert.ErrorIs(t, merge.Prev(), types.ErrNotReversible)
+}
@@ -1,6 +1,9 @@
 package types
 
-import "bytes"
+import (
+	"bytes"
+	"errors"
+)
 
 type Options struct {
 	skipOnDuplicate bool
@@ -18,10 +21,13 @@ type twoWayIter[A Iterator, B Iterator] struct {
 	a       A
 	b       B
 	chooseA bool
+	reverse bool
 	opts    *Options
 }
 
-func NewTwoWayIter[A Iterator, B Iterator](a A, b B, options ...Option) Iterator {
+// NewTwoWayIter merges two sorted iterators, equal keys are returned from a then b
+// unless SkipOnDuplicate. Moving backward returns ErrNotReversible unless both are ReverseIterators
+func NewTwoWayIter[A Iterator, B Iterator](a A, b B, options ...Option) ReverseIterator {
 	opts := &Options{skipOnDuplicate: false}
 
 	for _, opt := range options {
@@ -34,10 +40,7 @@ func NewTwoWayIter[A Iterator, B Iterator](a A, b B, options ...Option) Iterator
 		chooseA: false,
 		opts:    opts,
 	}
-	if iter.opts.skipOnDuplicate {
-		iter.skipAOnEqual()
-	}
-	iter.chooseA = iter.shouldChooseA()
+	iter.choose()
 
 	return iter
 }
@@ -59,20 +62,49 @@ func (t *twoWayIter[A, B]) Key() Bytes {
 }
 
 func (t *twoWayIter[A, B]) Next() error {
+	if t.reverse {
+		if !t.HasNext() {
+			return ErrIterEnd
+		}
+		return t.turn(false)
+	}
+
 	var err error
 	if t.chooseA {
 		err = t.a.Next()
 	} else {
 		err = t.b.Next()
 	}
-	if err != nil {
+	if err != nil && !errors.Is(err, ErrIterEnd) {
 		return err
 	}
 
-	if t.opts.skipOnDuplicate {
-		t.skipAOnEqual()
+	t.choose()
+	return nil
+}
+
+func (t *twoWayIter[A, B]) Prev() error {
+	if !t.HasNext() {
+		return ErrIterEnd
 	}
-	t.chooseA = t.shouldChooseA()
+	if !t.reverse {
+		return t.turn(true)
+	}
+
+	a, b, err := t.reversible()
+	if err != nil {
+		return err
+	}
+	if t.chooseA {
+		err = a.Prev()
+	} else {
+		err = b.Prev()
+	}
+	if err != nil && !errors.Is(err, ErrIterEnd) {
+		return err
+	}
+
+	t.choose()
 	return nil
 }
 
@@ -84,6 +116,122 @@ func (t *twoWayIter[A, B]) Value() Bytes {
 	}
 }
 
+func (t *twoWayIter[A, B]) reversible() (ReverseIterator, ReverseIterator, error) {
+	a, okA := any(t.a).(ReverseIterator)
+	b, okB := any(t.b).(ReverseIterator)
+	if !okA || !okB {
+		return nil, nil, ErrNotReversible
+	}
+	return a, b, nil
+}
+
+// turn changes direction, the iterator not chosen moves to the other side of the current key
+func (t *twoWayIter[A, B]) turn(reverse bool) error {
+	a, b, err := t.reversible()
+	if err != nil {
+		return err
+	}
+	key := t.Key()
+
+	// in forward order, a comes before b on equal keys
+	switch {
+	case reverse && t.chooseA:
+		err = errors.Join(a.Prev(), seekBefore(b, key))
+	case reverse:
+		err = b.Prev()
+		if t.opts.skipOnDuplicate {
+			err = errors.Join(err, seekBefore(a, key))
+		} else {
+			err = errors.Join(err, a.SeekForPrev(key))
+		}
+	case t.chooseA:
+		err = errors.Join(a.Next(), b.SeekToKey(key))
+	default:
+		err = errors.Join(b.Next(), seekAfter(a, key))
+	}
+	if err != nil && !isIterEnd(err) {
+		return err
+	}
+
+	t.reverse = reverse
+	t.choose()
+	return nil
+}
+
+// seekBefore moves it to the last key < key
+func seekBefore(it ReverseIterator, key Bytes) error {
+	if err := it.SeekForPrev(key); err != nil {
+		return err
+	}
+	if it.HasNext() && bytes.Equal(it.Key(), key) {
+		return it.Prev()
+	}
+	return nil
+}
+
+// seekAfter moves it to the first key > key
+func seekAfter(it ReverseIterator, key Bytes) error {
+	if err := it.SeekToKey(key); err != nil {
+		return err
+	}
+	if it.HasNext() && bytes.Equal(it.Key(), key) {
+		return it.Next()
+	}
+	return nil
+}
+
+// isIterEnd tells whether err only holds ErrIterEnd
+func isIterEnd(err error) bool {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			if !isIterEnd(e) {
+				return false
+			}
+		}
+		return true
+	}
+	return errors.Is(err, ErrIterEnd)
+}
+
+func (t *twoWayIter[A, B]) seek(seek func(ReverseIterator) error, reverse bool) error {
+	a, b, err := t.reversible()
+	if err != nil {
+		return err
+	}
+	if err := errors.Join(seek(a), seek(b)); err != nil && !isIterEnd(err) {
+		return err
+	}
+
+	t.reverse = reverse
+	t.choose()
+	return nil
+}
+
+func (t *twoWayIter[A, B]) SeekToLast() error {
+	return t.seek(func(it ReverseIterator) error {
+		return it.SeekToLast()
+	}, true)
+}
+
+func (t *twoWayIter[A, B]) SeekToKey(key Bytes) error {
+	return t.seek(func(it ReverseIterator) error {
+		return it.SeekToKey(key)
+	}, false)
+}
+
+func (t *twoWayIter[A, B]) SeekForPrev(key Bytes) error {
+	return t.seek(func(it ReverseIterator) error {
+		return it.SeekForPrev(key)
+	}, true)
+}
+
+func (t *twoWayIter[A, B]) choose() {
+	if t.opts.skipOnDuplicate {
+		t.skipAOnEqual()
+	}
+	t.chooseA = t.shouldChooseA()
+}
+
 func (t *twoWayIter[A, B]) shouldChooseA() bool {
 	if !t.a.HasNext() {
 		return false
@@ -91,11 +239,18 @@ func (t *twoWayIter[A, B]) shouldChooseA() bool {
 	if !t.b.HasNext() {
 		return true
 	}
+	if t.reverse {
+		return bytes.Compare(t.a.Key(), t.b.Key()) > 0
+	}
 	return bytes.Compare(t.a.Key(), t.b.Key()) <= 0
 }
 
 func (t *twoWayIter[A, B]) skipAOnEqual() {
 	if t.a.HasNext() && t.b.HasNext() && bytes.Equal(t.a.Key(), t.b.Key()) {
-		t.a.Next()
+		if t.reverse {
+			any(t.a).(ReverseIterator).Prev()
+		} else {
+			t.a.Next()
+		}
 	}
 }
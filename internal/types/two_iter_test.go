@@ -73,3 +73,72 @@ func TestTwoWayIter_EmptyB(t *testing.T) {
 	}
 	assert.Equal(t, []string{"m", "n"}, keys)
 }
+
+type fakeReverseIter struct {
+	fakeIter
+}
+
+func (f *fakeReverseIter) HasNext() bool {
+	return f.idx >= 0 && f.idx < len(f.keys)
+}
+
+func (f *fakeReverseIter) Prev() error {
+	f.idx--
+	return nil
+}
+
+func (f *fakeReverseIter) SeekToLast() error {
+	f.idx = len(f.keys) - 1
+	return nil
+}
+
+func (f *fakeReverseIter) SeekToKey(key Bytes) error {
+	f.idx = 0
+	for f.idx < len(f.keys) && BytesComparator(f.keys[f.idx], key) < 0 {
+		f.idx++
+	}
+	return nil
+}
+
+func (f *fakeReverseIter) SeekForPrev(key Bytes) error {
+	f.idx = len(f.keys) - 1
+	for f.idx >= 0 && BytesComparator(f.keys[f.idx], key) > 0 {
+		f.idx--
+	}
+	return nil
+}
+
+func TestTwoWayIter_Reverse(t *testing.T) {
+	a := &fakeReverseIter{fakeIter{
+		keys:   []Bytes{[]byte("a"), []byte("c"), []byte("e")},
+		values: []Bytes{[]byte("A"), []byte("C"), []byte("E")},
+	}}
+	b := &fakeReverseIter{fakeIter{
+		keys:   []Bytes{[]byte("b"), []byte("c"), []byte("d")},
+		values: []Bytes{[]byte("B"), []byte("C2"), []byte("D")},
+	}}
+	it := NewTwoWayIter(a, b, SkipOnDuplicate())
+
+	assert.NoError(t, it.SeekToLast())
+	var got []string
+	for it.HasNext() {
+		got = append(got, string(it.Value()))
+		it.Prev()
+	}
+	assert.Equal(t, []string{"E", "D", "C2", "B", "A"}, got)
+
+	// changing direction
+	assert.NoError(t, it.SeekToKey([]byte("c")))
+	assert.Equal(t, "C2", string(it.Value()))
+	assert.NoError(t, it.Prev())
+	assert.Equal(t, "B", string(it.Value()))
+	assert.NoError(t, it.Next())
+	assert.Equal(t, "C2", string(it.Value()))
+	assert.NoError(t, it.Next())
+	assert.Equal(t, "D", string(it.Value()))
+	assert.NoError(t, it.Prev())
+	assert.Equal(t, "C2", string(it.Value()))
+
+	assert.NoError(t, it.SeekForPrev([]byte("dd")))
+	assert.Equal(t, "D", string(it.Value()))
+}
@@ -0,0 +1,88 @@
+package arena
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Arena accounts for the memory handed out by its allocators. Allocators carve
+// items out of large slabs, so that a memtable is a few allocations for the
+// garbage collector and is released at once when it is dropped
+type Arena struct {
+	slabSize  int
+	size      atomic.Int64 // bytes handed out
+	allocated atomic.Int64 // bytes of slabs
+}
+
+func New(slabSize int) *Arena {
+	return &Arena{slabSize: slabSize}
+}
+
+// Size returns the number of bytes handed out by the allocators of the arena
+func (a *Arena) Size() int {
+	return int(a.size.Load())
+}
+
+// Allocated returns the number of bytes of slabs, including the unused end of the current ones
+func (a *Arena) Allocated() int {
+	return int(a.allocated.Load())
+}
+
+type slab[T any] struct {
+	items []T
+	next  atomic.Int64
+}
+
+// Allocator hands out items of type T from the slabs of an arena, safe for concurrent use
+type Allocator[T any] struct {
+	arena    *Arena
+	itemSize int
+	perSlab  int
+	cur      atomic.Pointer[slab[T]]
+	lock     sync.Mutex // held to replace a full slab
+}
+
+func NewAllocator[T any](a *Arena) *Allocator[T] {
+	var zero T
+	itemSize := max(int(unsafe.Sizeof(zero)), 1)
+
+	return &Allocator[T]{
+		arena:    a,
+		itemSize: itemSize,
+		perSlab:  max(a.slabSize/itemSize, 1),
+	}
+}
+
+// Alloc returns n zeroed items, the capacity of the slice is n so that appending never overwrites other items
+func (al *Allocator[T]) Alloc(n int) []T {
+	al.arena.size.Add(int64(n * al.itemSize))
+
+	// large allocations would waste most of a slab
+	if n > al.perSlab/4 {
+		al.arena.allocated.Add(int64(n * al.itemSize))
+		return make([]T, n)
+	}
+
+	for {
+		s := al.cur.Load()
+		if s != nil {
+			end := int(s.next.Add(int64(n)))
+			if end <= len(s.items) {
+				return s.items[end-n : end : end]
+			}
+		}
+
+		al.lock.Lock()
+		if al.cur.Load() == s {
+			al.cur.Store(&slab[T]{items: make([]T, al.perSlab)})
+			al.arena.allocated.Add(int64(al.perSlab * al.itemSize))
+		}
+		al.lock.Unlock()
+	}
+}
+
+// New returns a pointer to a single item
+func (al *Allocator[T]) New() *T {
+	return &al.Alloc(1)[0]
+}
@@ -0,0 +1,72 @@
+package arena_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ttn-nguyen42/go-mini-lsm/pkg/arena"
+)
+
+func TestAllocator(t *testing.T) {
+	a := arena.New(64)
+	bytes := arena.NewAllocator[byte](a)
+
+	b1 := bytes.Alloc(10)
+	b2 := bytes.Alloc(10)
+	assert.Len(t, b1, 10)
+	assert.Equal(t, 10, cap(b1))
+	assert.Equal(t, 20, a.Size())
+	assert.Equal(t, 64, a.Allocated())
+
+	// appending to an item never overwrites the next one
+	copy(b2, "0123456789")
+	b1 = append(b1, 'x')
+	assert.Equal(t, "0123456789", string(b2))
+
+	// does not fit in the rest of the slab
+	bytes.Alloc(15)
+	bytes.Alloc(15)
+	bytes.Alloc(15)
+	assert.Equal(t, 128, a.Allocated())
+
+	// too large for a slab, allocated on its own
+	bytes.Alloc(100)
+	assert.Equal(t, 228, a.Allocated())
+	assert.Equal(t, 10+10+15*3+100, a.Size())
+
+	ints := arena.NewAllocator[int64](a)
+	n := ints.New()
+	*n = 42
+	assert.Equal(t, 10+10+15*3+100+8, a.Size())
+}
+
+func TestAllocatorConcurrent(t *testing.T) {
+	a := arena.New(1024)
+	ints := arena.NewAllocator[int](a)
+
+	const goroutines, allocs = 8, 1000
+	results := make([][]*int, goroutines)
+
+	var wg sync.WaitGroup
+	for g := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range allocs {
+				p := ints.New()
+				*p = g*allocs + i
+				results[g] = append(results[g], p)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// no item was handed out twice
+	for g := range goroutines {
+		for i, p := range results[g] {
+			assert.Equal(t, g*allocs+i, *p)
+		}
+	}
+	assert.Equal(t, goroutines*allocs*8, a.Size())
+}
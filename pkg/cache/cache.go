@@ -15,13 +15,18 @@ type Sizeable interface {
 type Cache[K Sizeable, V Sizeable] interface {
 	Set(key K, value V) (old V, replaced bool)
 	Get(key K) (value V, ok bool)
-	GetOrSet(key K, gen func() (V, bool)) (value V, isSet bool)
+	// GetOrSet returns the cached value of key, or the value generated by gen and set
+	// when key is missing. Generators run without locking the cache and concurrent
+	// calls for the same key share a single run of gen. Errors of gen are returned
+	// and nothing is cached
+	GetOrSet(key K, gen func() (V, error)) (value V, isSet bool, err error)
 	Delete(key K) (ok bool)
 	Contains(key K) (ok bool)
 	Clear()
 	Len() int
 	Cap() int
 	SizeBytes() int
+	Stats() Stats
 }
 
 type lruItem[K comparable, V any] struct {
@@ -37,17 +42,30 @@ type lruCache[K Sizeable, V Sizeable] struct {
 	head      *lruItem[K, V] // most recently used
 	tail      *lruItem[K, V] // least recently used
 	data      map[K]*lruItem[K, V]
-	cap       int
+	cap       int // in bytes of keys and values, or in entries with WithEntryCapacity
 	numItems  int // number of items
 	sizeBytes int // total size in bytes of all keys and values
 
-	opts *Options
+	opts   *Options
+	loader loader[K, V]
+	stats  *recorder[K]
 }
 
+// New creates a cache holding up to size bytes of keys and values,
+// evicting with LRU unless another policy is picked with WithPolicy
 func New[K Sizeable, V Sizeable](size int, options ...Option) Cache[K, V] {
 	opts := newOptions(options...)
 
-	return newLruCache[K, V](size, opts)
+	return newCache[K, V](size, opts)
+}
+
+func newCache[K Sizeable, V Sizeable](size int, opts *Options) Cache[K, V] {
+	switch opts.policy {
+	case S3FIFO:
+		return newS3FifoCache[K, V](size, opts)
+	default:
+		return newLruCache[K, V](size, opts)
+	}
 }
 
 type eviction[K comparable, V any] struct {
@@ -56,6 +74,17 @@ type eviction[K comparable, V any] struct {
 	ts  int64
 }
 
+// notifyEvicted calls the eviction hook for entries evicted while the cache was locked,
+// only once the lock is released since hooks can be slow
+func notifyEvicted[K comparable, V any](opts *Options, evicted []*eviction[K, V]) {
+	if opts.evictHook == nil {
+		return
+	}
+	for _, e := range evicted {
+		opts.evictHook(e.key, e.val, e.ts)
+	}
+}
+
 func newLruCache[K Sizeable, V Sizeable](size int, opts *Options) *lruCache[K, V] {
 	head := &lruItem[K, V]{}
 	tail := &lruItem[K, V]{}
@@ -71,6 +100,7 @@ func newLruCache[K Sizeable, V Sizeable](size int, opts *Options) *lruCache[K, V
 		tail:      tail,
 		data:      make(map[K]*lruItem[K, V]),
 		opts:      opts,
+		stats:     newRecorder[K](opts),
 	}
 }
 
@@ -86,6 +116,7 @@ func (l *lruCache[K, V]) clear() {
 	l.data = make(map[K]*lruItem[K, V])
 	l.head.next = l.tail
 	l.tail.prev = l.head
+	l.stats.clear()
 }
 
 func (l *lruCache[K, V]) Contains(key K) (ok bool) {
@@ -109,6 +140,7 @@ func (l *lruCache[K, V]) delete(key K) (ok bool) {
 		delete(l.data, key)
 		l.numItems -= 1
 		l.sizeBytes -= key.Size() + item.val.Size()
+		l.stats.remove(key, key.Size()+item.val.Size())
 		return true
 	}
 	return false
@@ -124,35 +156,38 @@ func (l *lruCache[K, V]) Get(key K) (value V, ok bool) {
 func (l *lruCache[K, V]) get(key K) (value V, ok bool) {
 	item, ok := l.data[key]
 	if !ok {
+		l.stats.miss(key)
 		return value, false
 	}
 
+	l.stats.hit(key)
 	l.pop(item)
 	l.pushRecent(item)
 	return item.val, true
 }
 
-func (l *lruCache[K, V]) GetOrSet(key K, gen func() (V, bool)) (value V, isSet bool) {
-	l.lock.Lock()
-	defer l.lock.Unlock()
+// peek looks key up without counting it nor making it recent
+func (l *lruCache[K, V]) peek(key K) (value V, ok bool) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
 
-	item, ok := l.get(key)
+	item, ok := l.data[key]
 	if !ok {
-		data, ok := gen()
-		if !ok {
-			return value, false
-		}
-
-		l.setEvicted(key, data)
-		return data, true
+		return value, false
 	}
-	return item, false
+	return item.val, true
+}
+
+func (l *lruCache[K, V]) GetOrSet(key K, gen func() (V, error)) (value V, isSet bool, err error) {
+	return l.loader.getOrSet(key, l.Get, l.peek, l.Set, gen)
 }
 
 func (l *lruCache[K, V]) Set(key K, value V) (old V, replaced bool) {
 	l.lock.Lock()
-	defer l.lock.Unlock()
-	old, replaced, _ = l.setEvicted(key, value)
+	old, replaced, evicted := l.setEvicted(key, value)
+	l.lock.Unlock()
+
+	notifyEvicted(l.opts, evicted)
 	return
 }
 
@@ -172,39 +207,67 @@ func (l *lruCache[K, V]) evictLeastRecent() *eviction[K, V] {
 	tail.prev = moreRecent
 	l.numItems -= 1
 	l.sizeBytes -= least.key.Size() + least.val.Size()
-
-	if l.opts != nil && l.opts.evictHook != nil {
-		l.opts.evictHook(least.key, least.val, time.Now().UnixMilli())
-	}
+	l.stats.evict(least.key, least.key.Size()+least.val.Size())
 
 	return &eviction[K, V]{key: least.key, val: least.val, ts: time.Now().UnixMilli()}
 }
 
-func (l *lruCache[K, V]) setEvicted(key K, value V) (old V, replace bool, e *eviction[K, V]) {
+func (l *lruCache[K, V]) setEvicted(key K, value V) (old V, replace bool, e []*eviction[K, V]) {
+	size := key.Size() + value.Size()
+
 	val, ok := l.data[key]
 	if !ok {
-		if l.shouldEvict() {
-			e = l.evictLeastRecent()
-			delete(l.data, e.key)
+		if !l.fits(size) {
+			// would evict everything else and still not fit
+			return old, false, nil
+		}
+		for l.shouldEvict(1, size) {
+			e = append(e, l.evict())
 		}
 		val = &lruItem[K, V]{key: key, val: value}
 		l.pushRecent(val)
 		l.data[key] = val
 		l.numItems += 1
-		l.sizeBytes += key.Size() + value.Size()
-		var old V
+		l.sizeBytes += size
+		l.stats.insert(key, size)
 		return old, false, e
 	}
 	old = val.val
+	l.stats.replace(key, size-(val.key.Size()+val.val.Size()))
 	l.sizeBytes -= val.key.Size() + val.val.Size()
 	val.val = value
-	l.sizeBytes += val.key.Size() + val.val.Size()
+	l.sizeBytes += size
 	l.makeRecent(val)
-	return old, true, nil
+	// a bigger value can push other entries out, never the entry itself
+	for l.numItems > 1 && l.shouldEvict(0, 0) {
+		e = append(e, l.evict())
+	}
+	return old, true, e
 }
 
-func (l *lruCache[K, V]) shouldEvict() bool {
-	return l.numItems >= l.cap
+func (l *lruCache[K, V]) evict() *eviction[K, V] {
+	e := l.evictLeastRecent()
+	delete(l.data, e.key)
+	return e
+}
+
+// fits returns false when an entry of size bytes is larger than the whole cache
+func (l *lruCache[K, V]) fits(size int) bool {
+	if l.opts.entryCapacity {
+		return l.cap > 0
+	}
+	return size <= l.cap
+}
+
+// shouldEvict returns true while adding items entries of size bytes would go over capacity
+func (l *lruCache[K, V]) shouldEvict(items int, size int) bool {
+	if l.numItems == 0 {
+		return false
+	}
+	if l.opts.entryCapacity {
+		return l.numItems+items > l.cap
+	}
+	return l.sizeBytes+size > l.cap
 }
 
 func (l *lruCache[K, V]) isMostRecent(h *lruItem[K, V]) bool {
@@ -246,3 +309,7 @@ func (l *lruCache[K, V]) Cap() int {
 
 	return l.cap
 }
+
+func (l *lruCache[K, V]) Stats() Stats {
+	return l.stats.stats()
+}
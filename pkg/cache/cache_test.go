@@ -1,14 +1,19 @@
 package cache
 
 import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
 )
 
 func TestCacheSetGet(t *testing.T) {
-	c := New[types.SizeableString, types.SizeableString](2)
+	c := New[types.SizeableString, types.SizeableString](2, WithEntryCapacity())
 	old, replaced := c.Set(types.SizeableString("a"), types.SizeableString("foo"))
 	assert.False(t, replaced)
 	assert.Equal(t, types.SizeableString(""), old)
@@ -19,7 +24,7 @@ func TestCacheSetGet(t *testing.T) {
 }
 
 func TestCacheReplace(t *testing.T) {
-	c := New[types.SizeableString, types.SizeableString](2)
+	c := New[types.SizeableString, types.SizeableString](2, WithEntryCapacity())
 	c.Set(types.SizeableString("a"), types.SizeableString("foo"))
 	old, replaced := c.Set(types.SizeableString("a"), types.SizeableString("bar"))
 	assert.True(t, replaced)
@@ -31,7 +36,7 @@ func TestCacheReplace(t *testing.T) {
 }
 
 func TestCacheEviction(t *testing.T) {
-	c := New[types.SizeableString, types.SizeableString](2)
+	c := New[types.SizeableString, types.SizeableString](2, WithEntryCapacity())
 	c.Set(types.SizeableString("a"), types.SizeableString("foo"))
 	c.Set(types.SizeableString("b"), types.SizeableString("bar"))
 	c.Set(types.SizeableString("c"), types.SizeableString("baz")) // should evict "a"
@@ -48,7 +53,7 @@ func TestCacheEviction(t *testing.T) {
 }
 
 func TestCacheDelete(t *testing.T) {
-	c := New[types.SizeableString, types.SizeableString](2)
+	c := New[types.SizeableString, types.SizeableString](2, WithEntryCapacity())
 	c.Set(types.SizeableString("a"), types.SizeableString("foo"))
 	ok := c.Delete(types.SizeableString("a"))
 	assert.True(t, ok)
@@ -60,7 +65,7 @@ func TestCacheDelete(t *testing.T) {
 }
 
 func TestCacheContains(t *testing.T) {
-	c := New[types.SizeableString, types.SizeableString](2)
+	c := New[types.SizeableString, types.SizeableString](2, WithEntryCapacity())
 	c.Set(types.SizeableString("a"), types.SizeableString("foo"))
 	assert.True(t, c.Contains(types.SizeableString("a")))
 	c.Delete(types.SizeableString("a"))
@@ -68,7 +73,7 @@ func TestCacheContains(t *testing.T) {
 }
 
 func TestCacheClear(t *testing.T) {
-	c := New[types.SizeableString, types.SizeableString](2)
+	c := New[types.SizeableString, types.SizeableString](2, WithEntryCapacity())
 	c.Set(types.SizeableString("a"), types.SizeableString("foo"))
 	c.Set(types.SizeableString("b"), types.SizeableString("bar"))
 	c.Clear()
@@ -79,17 +84,103 @@ func TestCacheClear(t *testing.T) {
 }
 
 func TestCacheGetOrSet(t *testing.T) {
-	c := New[types.SizeableString, types.SizeableString](2)
-	val, isSet := c.GetOrSet(types.SizeableString("a"), func() (types.SizeableString, bool) { return "foo", true })
+	c := New[types.SizeableString, types.SizeableString](2, WithEntryCapacity())
+	val, isSet, err := c.GetOrSet(types.SizeableString("a"), func() (types.SizeableString, error) { return "foo", nil })
+	assert.NoError(t, err)
 	assert.True(t, isSet)
 	assert.Equal(t, types.SizeableString("foo"), val)
-	val, isSet = c.GetOrSet(types.SizeableString("a"), func() (types.SizeableString, bool) { return "bar", true })
+	val, isSet, err = c.GetOrSet(types.SizeableString("a"), func() (types.SizeableString, error) { return "bar", nil })
+	assert.NoError(t, err)
 	assert.False(t, isSet)
 	assert.Equal(t, types.SizeableString("foo"), val)
 }
 
+func TestCacheGetOrSetError(t *testing.T) {
+	c := New[types.SizeableString, types.SizeableString](2, WithEntryCapacity())
+	_, isSet, err := c.GetOrSet(types.SizeableString("a"), func() (types.SizeableString, error) {
+		return "", errors.New("read failed")
+	})
+	assert.Error(t, err)
+	assert.False(t, isSet)
+	assert.False(t, c.Contains(types.SizeableString("a")))
+}
+
+func TestCacheGetOrSetSingleFlight(t *testing.T) {
+	for _, p := range []Policy{LRU, S3FIFO} {
+		c := New[types.SizeableString, types.SizeableString](2, WithEntryCapacity(), WithPolicy(p))
+
+		var calls atomic.Int32
+		release := make(chan struct{})
+		wg := sync.WaitGroup{}
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				val, _, err := c.GetOrSet(types.SizeableString("a"), func() (types.SizeableString, error) {
+					calls.Add(1)
+					<-release
+					return "foo", nil
+				})
+				assert.NoError(t, err)
+				assert.Equal(t, types.SizeableString("foo"), val)
+			}()
+		}
+
+		// other keys are not blocked by the slow load
+		c.Set(types.SizeableString("b"), types.SizeableString("bar"))
+		_, ok := c.Get(types.SizeableString("b"))
+		assert.True(t, ok)
+
+		close(release)
+		wg.Wait()
+		assert.Equal(t, int32(1), calls.Load())
+	}
+}
+
+func TestCacheGetOrSetPanic(t *testing.T) {
+	c := New[types.SizeableString, types.SizeableString](2, WithEntryCapacity())
+
+	started, release := make(chan struct{}), make(chan struct{})
+	panicked := make(chan any)
+	go func() {
+		defer func() {
+			panicked <- recover()
+		}()
+		c.GetOrSet(types.SizeableString("a"), func() (types.SizeableString, error) {
+			close(started)
+			<-release
+			panic("read failed")
+		})
+	}()
+	<-started
+
+	waited := make(chan error)
+	go func() {
+		val, _, err := c.GetOrSet(types.SizeableString("a"), func() (types.SizeableString, error) {
+			return "foo", nil
+		})
+		assert.Empty(t, val)
+		waited <- err
+	}()
+	// let the second call wait on the first load
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	assert.Equal(t, "read failed", <-panicked)
+	assert.ErrorIs(t, <-waited, ErrLoadPanicked)
+	assert.False(t, c.Contains(types.SizeableString("a")))
+
+	// the key can be loaded again
+	val, isSet, err := c.GetOrSet(types.SizeableString("a"), func() (types.SizeableString, error) {
+		return "foo", nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, isSet)
+	assert.Equal(t, types.SizeableString("foo"), val)
+}
+
 func TestCacheLenCap(t *testing.T) {
-	c := New[types.SizeableString, types.SizeableString](2)
+	c := New[types.SizeableString, types.SizeableString](2, WithEntryCapacity())
 	assert.Equal(t, 0, c.Len())
 	assert.Equal(t, 2, c.Cap())
 	c.Set(types.SizeableString("a"), types.SizeableString("foo"))
@@ -97,7 +188,7 @@ func TestCacheLenCap(t *testing.T) {
 }
 
 func TestCacheUpdateMostRecent(t *testing.T) {
-	c := New[types.SizeableString, types.SizeableString](3)
+	c := New[types.SizeableString, types.SizeableString](3, WithEntryCapacity())
 	c.Set(types.SizeableString("a"), types.SizeableString("foo"))
 	c.Set(types.SizeableString("b"), types.SizeableString("bar"))
 	c.Set(types.SizeableString("c"), types.SizeableString("baz"))
@@ -116,7 +207,7 @@ func TestCacheUpdateMostRecent(t *testing.T) {
 }
 
 func TestCacheUpdateNotMostRecent(t *testing.T) {
-	c := New[types.SizeableString, types.SizeableString](3)
+	c := New[types.SizeableString, types.SizeableString](3, WithEntryCapacity())
 	c.Set(types.SizeableString("a"), types.SizeableString("foo"))
 	c.Set(types.SizeableString("b"), types.SizeableString("bar"))
 	c.Set(types.SizeableString("c"), types.SizeableString("baz"))
@@ -149,7 +240,7 @@ func TestCacheEvictionHook(t *testing.T) {
 		evictedTS = ts
 	}
 
-	c := New[types.SizeableString, types.SizeableString](2, WithEvictHook(hook))
+	c := New[types.SizeableString, types.SizeableString](2, WithEntryCapacity(), WithEvictHook(hook))
 	c.Set(types.SizeableString("a"), types.SizeableString("foo"))
 	c.Set(types.SizeableString("b"), types.SizeableString("bar"))
 	c.Set(types.SizeableString("c"), types.SizeableString("baz")) // should evict "a"
@@ -158,3 +249,237 @@ func TestCacheEvictionHook(t *testing.T) {
 	assert.Equal(t, types.SizeableString("foo"), evictedVal)
 	assert.NotZero(t, evictedTS)
 }
+
+func TestCacheEvictionHookUnlocked(t *testing.T) {
+	for _, policy := range []Policy{LRU, S3FIFO} {
+		var c Cache[types.SizeableString, types.SizeableString]
+		// the hook can take the lock of the cache it was evicted from
+		locked := func() bool {
+			var lock *sync.RWMutex
+			switch cc := c.(type) {
+			case *lruCache[types.SizeableString, types.SizeableString]:
+				lock = &cc.lock
+			case *s3fifoCache[types.SizeableString, types.SizeableString]:
+				lock = &cc.lock
+			}
+			if !lock.TryLock() {
+				return true
+			}
+			lock.Unlock()
+			return false
+		}
+
+		var calls, lockedCalls int
+		hook := func(key any, value any, ts int64) {
+			calls += 1
+			if locked() {
+				lockedCalls += 1
+			}
+		}
+
+		c = newCache[types.SizeableString, types.SizeableString](2, newOptions(WithEntryCapacity(), WithEvictHook(hook), WithPolicy(policy)))
+		for _, k := range []string{"a", "b", "c", "d"} {
+			c.Set(types.SizeableString(k), types.SizeableString("v"))
+		}
+		assert.Equal(t, 2, calls)
+		assert.Zero(t, lockedCalls)
+	}
+}
+
+func TestCacheByteCapacity(t *testing.T) {
+	c := New[types.SizeableString, types.SizeableString](10)
+	c.Set(types.SizeableString("a"), types.SizeableString("foo"))
+	c.Set(types.SizeableString("b"), types.SizeableString("bar"))
+	assert.Equal(t, 8, c.SizeBytes())
+
+	// needs 5 bytes, only "a" has to go
+	c.Set(types.SizeableString("c"), types.SizeableString("bazz"))
+	assert.False(t, c.Contains(types.SizeableString("a")))
+	assert.True(t, c.Contains(types.SizeableString("b")))
+	assert.True(t, c.Contains(types.SizeableString("c")))
+	assert.Equal(t, 9, c.SizeBytes())
+
+	c.Set(types.SizeableString("d"), types.SizeableString("q"))
+	assert.False(t, c.Contains(types.SizeableString("b")))
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, 7, c.SizeBytes())
+
+	// growing a value evicts the others, not the value itself
+	c.Set(types.SizeableString("d"), types.SizeableString("quuxquux"))
+	assert.False(t, c.Contains(types.SizeableString("c")))
+	assert.True(t, c.Contains(types.SizeableString("d")))
+	assert.Equal(t, 9, c.SizeBytes())
+}
+
+func TestCacheByteCapacityTooLarge(t *testing.T) {
+	c := New[types.SizeableString, types.SizeableString](10)
+	c.Set(types.SizeableString("a"), types.SizeableString("foo"))
+
+	_, replaced := c.Set(types.SizeableString("b"), types.SizeableString("too large to fit"))
+	assert.False(t, replaced)
+	assert.False(t, c.Contains(types.SizeableString("b")))
+	assert.True(t, c.Contains(types.SizeableString("a")))
+}
+
+func TestShardedCache(t *testing.T) {
+	c := NewSharded[types.SizeableString, types.SizeableString](4, 4, WithEntryCapacity())
+	assert.Equal(t, 4, c.Cap())
+
+	for _, k := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		c.Set(types.SizeableString(k), types.SizeableString(k+k))
+	}
+	assert.LessOrEqual(t, c.Len(), 4)
+	assert.Equal(t, c.Len()*3, c.SizeBytes())
+
+	val, ok := c.Get(types.SizeableString("h"))
+	assert.True(t, ok)
+	assert.Equal(t, types.SizeableString("hh"), val)
+	assert.True(t, c.Delete(types.SizeableString("h")))
+	assert.False(t, c.Contains(types.SizeableString("h")))
+
+	c.Clear()
+	assert.Equal(t, 0, c.Len())
+	assert.Equal(t, 0, c.SizeBytes())
+}
+
+func TestS3FifoScanResistance(t *testing.T) {
+	c := New[types.SizeableString, types.SizeableString](10, WithEntryCapacity(), WithPolicy(S3FIFO))
+	hot := []types.SizeableString{"h1", "h2", "h3"}
+	for _, k := range hot {
+		c.Set(k, "hot")
+	}
+	for _, k := range hot {
+		c.Get(k)
+	}
+
+	// a scan reads many entries once
+	for i := range 100 {
+		c.Set(types.SizeableString(fmt.Sprintf("scan%03d", i)), "cold")
+	}
+
+	assert.LessOrEqual(t, c.Len(), 10)
+	for _, k := range hot {
+		assert.True(t, c.Contains(k), "hot key %s evicted by scan", k)
+	}
+}
+
+func TestS3FifoBasic(t *testing.T) {
+	c := New[types.SizeableString, types.SizeableString](8, WithPolicy(S3FIFO))
+	old, replaced := c.Set("a", "foo")
+	assert.False(t, replaced)
+	assert.Equal(t, types.SizeableString(""), old)
+
+	old, replaced = c.Set("a", "bar")
+	assert.True(t, replaced)
+	assert.Equal(t, types.SizeableString("foo"), old)
+	assert.Equal(t, 4, c.SizeBytes())
+
+	c.Set("b", "baz")
+	c.Set("c", "qux") // over 8 bytes, "a" goes
+	assert.False(t, c.Contains("a"))
+	assert.Equal(t, 8, c.SizeBytes())
+
+	val, isSet, err := c.GetOrSet("b", func() (types.SizeableString, error) { return "new", nil })
+	assert.NoError(t, err)
+	assert.False(t, isSet)
+	assert.Equal(t, types.SizeableString("baz"), val)
+
+	assert.True(t, c.Delete("b"))
+	assert.Equal(t, 4, c.SizeBytes())
+	c.Clear()
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestCacheStats(t *testing.T) {
+	for _, p := range []Policy{LRU, S3FIFO} {
+		c := New[types.SizeableString, types.SizeableString](2, WithEntryCapacity(), WithPolicy(p))
+		c.Set("a", "foo")
+		c.Set("b", "bar")
+		c.Get("a")
+		c.Get("x")
+		c.Set("c", "baz") // evicts one entry
+		c.Delete("c")
+
+		st := c.Stats()
+		assert.Equal(t, uint64(1), st.Hits)
+		assert.Equal(t, uint64(1), st.Misses)
+		assert.Equal(t, uint64(3), st.Inserts)
+		assert.Equal(t, uint64(1), st.Evictions)
+		assert.Equal(t, c.Len(), st.Len)
+		assert.Equal(t, c.SizeBytes(), st.SizeBytes)
+		assert.Nil(t, st.Categories)
+
+		c.Clear()
+		assert.Equal(t, 0, c.Stats().Len)
+		assert.Equal(t, 0, c.Stats().SizeBytes)
+	}
+}
+
+func TestCacheStatsCategories(t *testing.T) {
+	byFirstByte := func(key any) string {
+		return string(key.(types.SizeableString)[:1])
+	}
+	c := NewSharded[types.SizeableString, types.SizeableString](100, 4, WithCategorizer(byFirstByte))
+	c.Set("d1", "x")
+	c.Set("d2", "x")
+	c.Set("i1", "x")
+	c.Get("d1")
+	c.Get("i2")
+	_, _, err := c.GetOrSet("i3", func() (types.SizeableString, error) { return "x", nil })
+	assert.NoError(t, err)
+
+	st := c.Stats()
+	assert.Equal(t, uint64(1), st.Categories["d"].Hits)
+	assert.Equal(t, 2, st.Categories["d"].Len)
+	assert.Equal(t, uint64(2), st.Categories["i"].Misses)
+	assert.Equal(t, 2, st.Categories["i"].Len)
+	assert.Equal(t, 4, st.Len)
+	assert.Equal(t, 12, st.SizeBytes)
+}
+
+type stringCodec struct{}
+
+func (stringCodec) Encode(v types.SizeableString) ([]byte, error) {
+	return []byte(v), nil
+}
+
+func (stringCodec) Decode(data []byte) (types.SizeableString, error) {
+	return types.SizeableString(data), nil
+}
+
+func TestTieredCache(t *testing.T) {
+	secondary := NewSecondary[types.SizeableString, types.SizeableString](1024, stringCodec{})
+	primary := New[types.SizeableString, types.SizeableString](2, WithEntryCapacity(), WithEvictHook(secondary.EvictHook()))
+	c := NewTiered(primary, secondary)
+
+	c.Set(types.SizeableString("a"), types.SizeableString("foo"))
+	c.Set(types.SizeableString("b"), types.SizeableString("bar"))
+	c.Set(types.SizeableString("c"), types.SizeableString("baz")) // evicts "a" to the secondary cache
+	assert.False(t, primary.Contains(types.SizeableString("a")))
+	assert.True(t, secondary.Contains(types.SizeableString("a")))
+	assert.True(t, c.Contains(types.SizeableString("a")))
+
+	// promoted back, "b" goes to the secondary cache in its place
+	val, ok := c.Get(types.SizeableString("a"))
+	assert.True(t, ok)
+	assert.Equal(t, types.SizeableString("foo"), val)
+	assert.True(t, primary.Contains(types.SizeableString("a")))
+	assert.False(t, secondary.Contains(types.SizeableString("a")))
+	assert.True(t, secondary.Contains(types.SizeableString("b")))
+
+	gens := 0
+	val, isSet, err := c.GetOrSet(types.SizeableString("b"), func() (types.SizeableString, error) {
+		gens += 1
+		return types.SizeableString("gen"), nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, isSet)
+	assert.Equal(t, types.SizeableString("bar"), val)
+	assert.Equal(t, 0, gens)
+
+	assert.True(t, c.Delete(types.SizeableString("c")))
+	assert.False(t, c.Contains(types.SizeableString("c")))
+	_, ok = c.Get(types.SizeableString("missing"))
+	assert.False(t, ok)
+	assert.Equal(t, uint64(2), secondary.Stats().Hits)
+}
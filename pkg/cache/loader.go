@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ErrLoadPanicked is returned to the calls waiting on a load whose generator panicked
+var ErrLoadPanicked error = fmt.Errorf("cache generator panicked")
+
+type load[V any] struct {
+	done chan struct{}
+	val  V
+	err  error
+}
+
+// loader runs the generators of GetOrSet without holding the cache lock,
+// concurrent loads of the same key wait for the first one instead of running again
+type loader[K comparable, V any] struct {
+	lock  sync.Mutex
+	loads map[K]*load[V]
+}
+
+// getOrSet looks key up with get, then with peek once it holds the load of key
+// so that a single miss is counted
+func (l *loader[K, V]) getOrSet(key K, get func(K) (V, bool), peek func(K) (V, bool), set func(K, V) (V, bool), gen func() (V, error)) (value V, isSet bool, err error) {
+	if val, ok := get(key); ok {
+		return val, false, nil
+	}
+
+	l.lock.Lock()
+	if ld, ok := l.loads[key]; ok {
+		l.lock.Unlock()
+		<-ld.done
+		return ld.val, false, ld.err
+	}
+	if l.loads == nil {
+		l.loads = make(map[K]*load[V])
+	}
+	ld := &load[V]{done: make(chan struct{})}
+	l.loads[key] = ld
+	l.lock.Unlock()
+
+	defer func() {
+		l.lock.Lock()
+		delete(l.loads, key)
+		l.lock.Unlock()
+		close(ld.done)
+	}()
+
+	// a load of the same key may have finished between the miss and now
+	if val, ok := peek(key); ok {
+		ld.val = val
+		return val, false, nil
+	}
+
+	// the panic goes on in the caller, waiters see the load failed
+	panicked := true
+	defer func() {
+		if panicked {
+			var empty V
+			ld.val, ld.err = empty, ErrLoadPanicked
+		}
+	}()
+
+	ld.val, ld.err = gen()
+	panicked = false
+	if ld.err != nil {
+		return value, false, ld.err
+	}
+	set(key, ld.val)
+
+	return ld.val, true, nil
+}
@@ -1,9 +1,23 @@
 package cache
 
 type Options struct {
-	evictHook EvictionHook
+	evictHook     EvictionHook
+	entryCapacity bool
+	policy        Policy
+	categorizer   Categorizer
 }
 
+// Policy decides which entries are evicted when the cache is full
+type Policy uint8
+
+const (
+	// LRU evicts the least recently used entry
+	LRU Policy = iota
+	// S3FIFO keeps new entries in a small queue and only promotes those accessed
+	// again, so a scan reading every block once does not flush the hot entries
+	S3FIFO
+)
+
 type Option func(*Options)
 
 type EvictionHook func(key any, value any, ts int64)
@@ -14,6 +28,28 @@ func WithEvictHook(h EvictionHook) Option {
 	}
 }
 
+// WithEntryCapacity makes the capacity of the cache a number of entries instead of bytes
+func WithEntryCapacity() Option {
+	return func(o *Options) {
+		o.entryCapacity = true
+	}
+}
+
+func WithPolicy(p Policy) Option {
+	return func(o *Options) {
+		o.policy = p
+	}
+}
+
+// Categorizer names the category of a key, statistics are broken down by category
+type Categorizer func(key any) string
+
+func WithCategorizer(c Categorizer) Option {
+	return func(o *Options) {
+		o.categorizer = c
+	}
+}
+
 func newOptions(options ...Option) *Options {
 	opts := &Options{}
 	for _, opt := range options {
@@ -0,0 +1,302 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	s3fifoSmallRatio = 10 // percent of the capacity given to the small queue
+	s3fifoMaxFreq    = 3
+)
+
+type s3fifoItem[K comparable, V any] struct {
+	key   K
+	val   V
+	freq  atomic.Int32
+	main  bool // in the main queue, in the small queue otherwise
+	entry *list.Element
+}
+
+// s3fifoCache implements S3-FIFO (Yang et al., SOSP'23). Entries are admitted
+// into a small FIFO queue and only move to the main queue when accessed again
+// before leaving it, keys evicted from the small queue are remembered in a
+// ghost queue so that they go straight to the main queue when they come back.
+// Hits only bump a counter, so reads share the lock
+type s3fifoCache[K Sizeable, V Sizeable] struct {
+	lock sync.RWMutex
+
+	small  *list.List // of *s3fifoItem, front is the newest
+	main   *list.List
+	ghost  *list.List // of K
+	data   map[K]*s3fifoItem[K, V]
+	ghosts map[K]*list.Element
+
+	cap       int
+	smallUsed int // capacity used by the small queue
+	mainUsed  int
+	numItems  int
+	sizeBytes int
+
+	opts   *Options
+	loader loader[K, V]
+	stats  *recorder[K]
+}
+
+func newS3FifoCache[K Sizeable, V Sizeable](size int, opts *Options) *s3fifoCache[K, V] {
+	return &s3fifoCache[K, V]{
+		small:  list.New(),
+		main:   list.New(),
+		ghost:  list.New(),
+		data:   make(map[K]*s3fifoItem[K, V]),
+		ghosts: make(map[K]*list.Element),
+		cap:    size,
+		opts:   opts,
+		stats:  newRecorder[K](opts),
+	}
+}
+
+// weight is the share of the capacity used by an entry of size bytes
+func (s *s3fifoCache[K, V]) weight(size int) int {
+	if s.opts.entryCapacity {
+		return 1
+	}
+	return size
+}
+
+func (s *s3fifoCache[K, V]) itemWeight(item *s3fifoItem[K, V]) int {
+	return s.weight(item.key.Size() + item.val.Size())
+}
+
+func (s *s3fifoCache[K, V]) Get(key K) (value V, ok bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.get(key)
+}
+
+func (s *s3fifoCache[K, V]) get(key K) (value V, ok bool) {
+	item, ok := s.data[key]
+	if !ok {
+		s.stats.miss(key)
+		return value, false
+	}
+
+	s.stats.hit(key)
+	if f := item.freq.Load(); f < s3fifoMaxFreq {
+		item.freq.CompareAndSwap(f, f+1)
+	}
+	return item.val, true
+}
+
+// peek looks key up without counting it as an access
+func (s *s3fifoCache[K, V]) peek(key K) (value V, ok bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	item, ok := s.data[key]
+	if !ok {
+		return value, false
+	}
+	return item.val, true
+}
+
+func (s *s3fifoCache[K, V]) GetOrSet(key K, gen func() (V, error)) (value V, isSet bool, err error) {
+	return s.loader.getOrSet(key, s.Get, s.peek, s.Set, gen)
+}
+
+func (s *s3fifoCache[K, V]) Set(key K, value V) (old V, replaced bool) {
+	s.lock.Lock()
+	old, replaced, evicted := s.set(key, value)
+	s.lock.Unlock()
+
+	notifyEvicted(s.opts, evicted)
+	return old, replaced
+}
+
+func (s *s3fifoCache[K, V]) set(key K, value V) (old V, replaced bool, e []*eviction[K, V]) {
+	if item, ok := s.data[key]; ok {
+		old = item.val
+		s.remove(item)
+		return old, true, s.insert(key, value, item.main)
+	}
+
+	_, inGhost := s.ghosts[key]
+	if inGhost {
+		s.ghost.Remove(s.ghosts[key])
+		delete(s.ghosts, key)
+	}
+	return old, false, s.insert(key, value, inGhost)
+}
+
+func (s *s3fifoCache[K, V]) insert(key K, value V, main bool) (e []*eviction[K, V]) {
+	size := key.Size() + value.Size()
+	w := s.weight(size)
+	if s.cap <= 0 || w > s.cap {
+		// would evict everything else and still not fit
+		return nil
+	}
+	for s.numItems > 0 && s.smallUsed+s.mainUsed+w > s.cap {
+		if evicted := s.evict(); evicted != nil {
+			e = append(e, evicted)
+		}
+	}
+
+	item := &s3fifoItem[K, V]{key: key, val: value, main: main}
+	if main {
+		item.entry = s.main.PushFront(item)
+		s.mainUsed += w
+	} else {
+		item.entry = s.small.PushFront(item)
+		s.smallUsed += w
+	}
+	s.data[key] = item
+	s.numItems += 1
+	s.sizeBytes += size
+	s.stats.insert(key, size)
+	return e
+}
+
+// remove drops item from its queue without calling the eviction hook
+func (s *s3fifoCache[K, V]) remove(item *s3fifoItem[K, V]) {
+	w := s.itemWeight(item)
+	if item.main {
+		s.main.Remove(item.entry)
+		s.mainUsed -= w
+	} else {
+		s.small.Remove(item.entry)
+		s.smallUsed -= w
+	}
+	delete(s.data, item.key)
+	s.numItems -= 1
+	s.sizeBytes -= item.key.Size() + item.val.Size()
+	s.stats.remove(item.key, item.key.Size()+item.val.Size())
+}
+
+func (s *s3fifoCache[K, V]) evict() *eviction[K, V] {
+	if s.main.Len() == 0 || (s.small.Len() > 0 && s.smallUsed*100 >= s.cap*s3fifoSmallRatio) {
+		return s.evictSmall()
+	}
+	return s.evictMain()
+}
+
+// evictSmall moves the oldest entry of the small queue to the main queue when
+// it was accessed since it was admitted, evicts it and remembers its key otherwise
+func (s *s3fifoCache[K, V]) evictSmall() *eviction[K, V] {
+	for s.small.Len() > 0 {
+		item := s.small.Back().Value.(*s3fifoItem[K, V])
+		w := s.itemWeight(item)
+		s.small.Remove(item.entry)
+		s.smallUsed -= w
+
+		if item.freq.Load() > 0 {
+			item.freq.Store(0)
+			item.main = true
+			item.entry = s.main.PushFront(item)
+			s.mainUsed += w
+			continue
+		}
+
+		e := s.drop(item)
+		s.remember(item.key)
+		return e
+	}
+	return s.evictMain()
+}
+
+// evictMain evicts the oldest entry of the main queue not accessed since it was
+// last looked at, accessed ones get another round with a lower frequency
+func (s *s3fifoCache[K, V]) evictMain() *eviction[K, V] {
+	for s.main.Len() > 0 {
+		item := s.main.Back().Value.(*s3fifoItem[K, V])
+		if f := item.freq.Load(); f > 0 {
+			item.freq.Store(f - 1)
+			s.main.MoveToFront(item.entry)
+			continue
+		}
+
+		s.main.Remove(item.entry)
+		s.mainUsed -= s.itemWeight(item)
+		return s.drop(item)
+	}
+	return nil
+}
+
+// drop forgets an item already removed from its queue
+func (s *s3fifoCache[K, V]) drop(item *s3fifoItem[K, V]) *eviction[K, V] {
+	delete(s.data, item.key)
+	s.numItems -= 1
+	s.sizeBytes -= item.key.Size() + item.val.Size()
+	s.stats.evict(item.key, item.key.Size()+item.val.Size())
+
+	return &eviction[K, V]{key: item.key, val: item.val, ts: time.Now().UnixMilli()}
+}
+
+// remember keeps as many evicted keys as there are entries in the cache
+func (s *s3fifoCache[K, V]) remember(key K) {
+	s.ghosts[key] = s.ghost.PushFront(key)
+	for s.ghost.Len() > max(s.numItems, 1) {
+		oldest := s.ghost.Back()
+		s.ghost.Remove(oldest)
+		delete(s.ghosts, oldest.Value.(K))
+	}
+}
+
+func (s *s3fifoCache[K, V]) Delete(key K) (ok bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	item, ok := s.data[key]
+	if ok {
+		s.remove(item)
+	}
+	return ok
+}
+
+func (s *s3fifoCache[K, V]) Contains(key K) (ok bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	_, ok = s.data[key]
+	return
+}
+
+func (s *s3fifoCache[K, V]) Clear() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.small.Init()
+	s.main.Init()
+	s.ghost.Init()
+	s.data = make(map[K]*s3fifoItem[K, V])
+	s.ghosts = make(map[K]*list.Element)
+	s.smallUsed = 0
+	s.mainUsed = 0
+	s.numItems = 0
+	s.sizeBytes = 0
+	s.stats.clear()
+}
+
+func (s *s3fifoCache[K, V]) Len() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.numItems
+}
+
+func (s *s3fifoCache[K, V]) Cap() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.cap
+}
+
+func (s *s3fifoCache[K, V]) SizeBytes() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.sizeBytes
+}
+
+func (s *s3fifoCache[K, V]) Stats() Stats {
+	return s.stats.stats()
+}
@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+)
+
+// Codec turns the values of a cache into bytes and back, for the secondary cache to compress them
+type Codec[V any] interface {
+	Encode(value V) ([]byte, error)
+	Decode(data []byte) (V, error)
+}
+
+type compressed struct {
+	data []byte
+}
+
+func (c *compressed) Size() int {
+	return len(c.data)
+}
+
+// SecondaryCache keeps the values evicted from a primary cache compressed,
+// so that more of them fit in memory than the primary cache can hold.
+// Values found in the secondary cache are removed from it and promoted back to the primary
+type SecondaryCache[K Sizeable, V Sizeable] struct {
+	cache Cache[K, *compressed]
+	codec Codec[V]
+}
+
+// NewSecondary creates a secondary cache holding up to size bytes of keys and compressed values
+func NewSecondary[K Sizeable, V Sizeable](size int, codec Codec[V], options ...Option) *SecondaryCache[K, V] {
+	return &SecondaryCache[K, V]{
+		cache: New[K, *compressed](size, options...),
+		codec: codec,
+	}
+}
+
+// EvictHook inserts the values evicted from the primary cache, the primary cache
+// must be created with WithEvictHook(secondary.EvictHook())
+func (s *SecondaryCache[K, V]) EvictHook() EvictionHook {
+	return func(key any, value any, ts int64) {
+		// a value that cannot be encoded is dropped as it would be without a secondary cache
+		_ = s.Insert(key.(K), value.(V))
+	}
+}
+
+func (s *SecondaryCache[K, V]) Insert(key K, value V) error {
+	data, err := s.codec.Encode(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode value: %s", err)
+	}
+
+	buf := bytes.Buffer{}
+	w, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to compress value: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to compress value: %s", err)
+	}
+
+	s.cache.Set(key, &compressed{data: buf.Bytes()})
+	return nil
+}
+
+// Lookup returns the value of key and removes it from the secondary cache
+func (s *SecondaryCache[K, V]) Lookup(key K) (value V, ok bool, err error) {
+	data, ok := s.cache.Get(key)
+	if !ok {
+		return value, false, nil
+	}
+	s.cache.Delete(key)
+
+	r := flate.NewReader(bytes.NewReader(data.data))
+	defer r.Close()
+
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return value, false, fmt.Errorf("failed to decompress value: %s", err)
+	}
+	value, err = s.codec.Decode(raw)
+	if err != nil {
+		return value, false, fmt.Errorf("failed to decode value: %s", err)
+	}
+	return value, true, nil
+}
+
+func (s *SecondaryCache[K, V]) Contains(key K) bool {
+	return s.cache.Contains(key)
+}
+
+func (s *SecondaryCache[K, V]) Delete(key K) bool {
+	return s.cache.Delete(key)
+}
+
+func (s *SecondaryCache[K, V]) Clear() {
+	s.cache.Clear()
+}
+
+func (s *SecondaryCache[K, V]) Len() int {
+	return s.cache.Len()
+}
+
+func (s *SecondaryCache[K, V]) SizeBytes() int {
+	return s.cache.SizeBytes()
+}
+
+// Stats counts the lookups and the compressed sizes of the secondary cache
+func (s *SecondaryCache[K, V]) Stats() Stats {
+	return s.cache.Stats()
+}
+
+// tieredCache looks up the secondary cache when the primary one misses
+type tieredCache[K Sizeable, V Sizeable] struct {
+	Cache[K, V]
+	secondary *SecondaryCache[K, V]
+}
+
+// NewTiered puts secondary behind primary, values missing from primary are
+// looked up in secondary and promoted on hit. The primary cache must evict
+// to the secondary one, see EvictHook
+func NewTiered[K Sizeable, V Sizeable](primary Cache[K, V], secondary *SecondaryCache[K, V]) Cache[K, V] {
+	return &tieredCache[K, V]{
+		Cache:     primary,
+		secondary: secondary,
+	}
+}
+
+func (t *tieredCache[K, V]) Get(key K) (value V, ok bool) {
+	if value, ok = t.Cache.Get(key); ok {
+		return value, true
+	}
+	value, ok, err := t.secondary.Lookup(key)
+	if err != nil || !ok {
+		return value, false
+	}
+	t.Cache.Set(key, value)
+	return value, true
+}
+
+func (t *tieredCache[K, V]) GetOrSet(key K, gen func() (V, error)) (value V, isSet bool, err error) {
+	return t.Cache.GetOrSet(key, func() (V, error) {
+		value, ok, err := t.secondary.Lookup(key)
+		if err == nil && ok {
+			return value, nil
+		}
+		// a value that cannot be decoded is loaded again
+		return gen()
+	})
+}
+
+func (t *tieredCache[K, V]) Delete(key K) (ok bool) {
+	ok = t.Cache.Delete(key)
+	return t.secondary.Delete(key) || ok
+}
+
+func (t *tieredCache[K, V]) Contains(key K) (ok bool) {
+	return t.Cache.Contains(key) || t.secondary.Contains(key)
+}
+
+func (t *tieredCache[K, V]) Clear() {
+	t.Cache.Clear()
+	t.secondary.Clear()
+}
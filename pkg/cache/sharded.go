@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"hash/maphash"
+)
+
+// shardedCache splits keys between independent caches by hash so that
+// concurrent accesses to different shards do not contend on the same lock
+type shardedCache[K Sizeable, V Sizeable] struct {
+	shards []Cache[K, V]
+	seed   maphash.Seed
+}
+
+// NewSharded creates a cache of n shards sharing the capacity,
+// every shard evicts its own entries with the policy of the cache
+func NewSharded[K Sizeable, V Sizeable](size int, n int, options ...Option) Cache[K, V] {
+	if n < 1 {
+		n = 1
+	}
+	opts := newOptions(options...)
+
+	shardSize := (size + n - 1) / n
+	shards := make([]Cache[K, V], n)
+	for i := range shards {
+		shards[i] = newCache[K, V](shardSize, opts)
+	}
+
+	return &shardedCache[K, V]{
+		shards: shards,
+		seed:   maphash.MakeSeed(),
+	}
+}
+
+func (s *shardedCache[K, V]) shard(key K) Cache[K, V] {
+	if len(s.shards) == 1 {
+		return s.shards[0]
+	}
+	return s.shards[maphash.Comparable(s.seed, key)%uint64(len(s.shards))]
+}
+
+func (s *shardedCache[K, V]) Set(key K, value V) (old V, replaced bool) {
+	return s.shard(key).Set(key, value)
+}
+
+func (s *shardedCache[K, V]) Get(key K) (value V, ok bool) {
+	return s.shard(key).Get(key)
+}
+
+func (s *shardedCache[K, V]) GetOrSet(key K, gen func() (V, error)) (value V, isSet bool, err error) {
+	return s.shard(key).GetOrSet(key, gen)
+}
+
+func (s *shardedCache[K, V]) Delete(key K) (ok bool) {
+	return s.shard(key).Delete(key)
+}
+
+func (s *shardedCache[K, V]) Contains(key K) (ok bool) {
+	return s.shard(key).Contains(key)
+}
+
+func (s *shardedCache[K, V]) Clear() {
+	for _, sh := range s.shards {
+		sh.Clear()
+	}
+}
+
+func (s *shardedCache[K, V]) Len() int {
+	n := 0
+	for _, sh := range s.shards {
+		n += sh.Len()
+	}
+	return n
+}
+
+func (s *shardedCache[K, V]) Cap() int {
+	n := 0
+	for _, sh := range s.shards {
+		n += sh.Cap()
+	}
+	return n
+}
+
+func (s *shardedCache[K, V]) SizeBytes() int {
+	n := 0
+	for _, sh := range s.shards {
+		n += sh.SizeBytes()
+	}
+	return n
+}
+
+func (s *shardedCache[K, V]) Stats() Stats {
+	st := Stats{}
+	for _, sh := range s.shards {
+		st.merge(sh.Stats())
+	}
+	return st
+}
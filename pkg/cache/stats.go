@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// CategoryStats counts the activity of the cache, of a category of entries
+type CategoryStats struct {
+	Hits      uint64
+	Misses    uint64
+	Inserts   uint64 // entries added or replaced
+	Evictions uint64 // entries removed to make room, not deleted ones
+	Len       int
+	SizeBytes int
+}
+
+func (c *CategoryStats) add(o CategoryStats) {
+	c.Hits += o.Hits
+	c.Misses += o.Misses
+	c.Inserts += o.Inserts
+	c.Evictions += o.Evictions
+	c.Len += o.Len
+	c.SizeBytes += o.SizeBytes
+}
+
+// HitRate is the share of lookups that found their entry
+func (c CategoryStats) HitRate() float64 {
+	if c.Hits+c.Misses == 0 {
+		return 0
+	}
+	return float64(c.Hits) / float64(c.Hits+c.Misses)
+}
+
+type Stats struct {
+	CategoryStats
+	// Categories breaks the counters down by the category of keys, see WithCategorizer
+	Categories map[string]CategoryStats
+}
+
+func (s *Stats) merge(o Stats) {
+	s.CategoryStats.add(o.CategoryStats)
+	if o.Categories == nil {
+		return
+	}
+	if s.Categories == nil {
+		s.Categories = make(map[string]CategoryStats, len(o.Categories))
+	}
+	for name, cs := range o.Categories {
+		cur := s.Categories[name]
+		cur.add(cs)
+		s.Categories[name] = cur
+	}
+}
+
+type counters struct {
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	inserts   atomic.Uint64
+	evictions atomic.Uint64
+	len       atomic.Int64
+	sizeBytes atomic.Int64
+}
+
+func (c *counters) load() CategoryStats {
+	return CategoryStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Inserts:   c.inserts.Load(),
+		Evictions: c.evictions.Load(),
+		Len:       int(c.len.Load()),
+		SizeBytes: int(c.sizeBytes.Load()),
+	}
+}
+
+// recorder keeps the counters of a cache, lock free so that lookups sharing a read lock can count
+type recorder[K comparable] struct {
+	categorizer Categorizer
+	all         *counters // every key without a categorizer
+	categories  sync.Map  // of string to *counters
+}
+
+func newRecorder[K comparable](opts *Options) *recorder[K] {
+	r := &recorder[K]{categorizer: opts.categorizer}
+	if r.categorizer == nil {
+		r.all = &counters{}
+		r.categories.Store("", r.all)
+	}
+	return r
+}
+
+func (r *recorder[K]) counters(key K) *counters {
+	if r.all != nil {
+		return r.all
+	}
+
+	name := r.categorizer(key)
+	if c, ok := r.categories.Load(name); ok {
+		return c.(*counters)
+	}
+	c, _ := r.categories.LoadOrStore(name, &counters{})
+	return c.(*counters)
+}
+
+func (r *recorder[K]) hit(key K) {
+	r.counters(key).hits.Add(1)
+}
+
+func (r *recorder[K]) miss(key K) {
+	r.counters(key).misses.Add(1)
+}
+
+func (r *recorder[K]) insert(key K, size int) {
+	c := r.counters(key)
+	c.inserts.Add(1)
+	c.len.Add(1)
+	c.sizeBytes.Add(int64(size))
+}
+
+// replace accounts for the new size of a replaced entry
+func (r *recorder[K]) replace(key K, delta int) {
+	c := r.counters(key)
+	c.inserts.Add(1)
+	c.sizeBytes.Add(int64(delta))
+}
+
+func (r *recorder[K]) remove(key K, size int) {
+	c := r.counters(key)
+	c.len.Add(-1)
+	c.sizeBytes.Add(-int64(size))
+}
+
+func (r *recorder[K]) evict(key K, size int) {
+	r.remove(key, size)
+	r.counters(key).evictions.Add(1)
+}
+
+// clear keeps the counters but forgets the entries
+func (r *recorder[K]) clear() {
+	r.categories.Range(func(_, c any) bool {
+		c.(*counters).len.Store(0)
+		c.(*counters).sizeBytes.Store(0)
+		return true
+	})
+}
+
+func (r *recorder[K]) stats() Stats {
+	s := Stats{}
+	if r.categorizer != nil {
+		s.Categories = make(map[string]CategoryStats)
+	}
+	r.categories.Range(func(name, c any) bool {
+		cs := c.(*counters).load()
+		s.CategoryStats.add(cs)
+		if s.Categories != nil {
+			s.Categories[name.(string)] = cs
+		}
+		return true
+	})
+	return s
+}
@@ -43,6 +43,7 @@ func (c *cli) register() {
 	c.cmds = append(c.cmds, &getCmd{lsm: c.lsm, buf: c.buf})
 	c.cmds = append(c.cmds, &delCmd{lsm: c.lsm, buf: c.buf})
 	c.cmds = append(c.cmds, &scanCmd{lsm: c.lsm, buf: c.buf})
+	c.cmds = append(c.cmds, &statsCmd{lsm: c.lsm, buf: c.buf})
 }
 
 func (c *cli) Loop() {
@@ -26,6 +26,7 @@ Available commands:
 4. get <key>: Retrieve the value for a given key
 5. del <key>: Delete a key-value pair
 6. scan: Scan all key-value pairs
+7. stats: Show block, secondary and row cache statistics and memtable sizes
 `
 
 	_, err := c.buf.Write([]byte(helpText))
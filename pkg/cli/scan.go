@@ -21,15 +21,15 @@ func (c *scanCmd) Execute(args []string) (bool, error) {
 		return true, nil
 	}
 
-	iter := c.lsm.Scan(types.Bound[types.Bytes]{}, types.Bound[types.Bytes]{})
+	iter := c.lsm.Scan(types.Unbounded[types.Bytes](), types.Unbounded[types.Bytes]())
 
-	for iter.HasNext() {
+	defer iter.Close()
+
+	for ; iter.Valid(); iter.Next() {
 		key := iter.Key()
 		value := iter.Value()
 		c.buf.Write([]byte(key.String() + ": " + value.String() + "\n"))
-
-		iter.Next()
 	}
 
-	return true, nil
+	return true, iter.Err()
 }
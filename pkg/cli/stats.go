@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ttn-nguyen42/go-mini-lsm/pkg/cache"
+	"github.com/ttn-nguyen42/go-mini-lsm/pkg/lsm"
+)
+
+type statsCmd struct {
+	lsm lsm.LSM
+	buf io.Writer
+}
+
+func (c *statsCmd) ShouldRun(args []string) bool {
+	return args[0] == "stats"
+}
+
+func (c *statsCmd) Execute(args []string) (bool, error) {
+	if len(args) != 0 {
+		return true, fmt.Errorf("stats command does not take any arguments")
+	}
+
+	st := c.lsm.Stats()
+	fmt.Fprintf(c.buf, "memtables: count=%d bytes=%d logical bytes=%d\n", st.MemTables, st.MemTableSize, st.MemTableLogicalSize)
+	c.printStats("block cache", st.BlockCache)
+	if st.SecondaryCache != nil {
+		c.printStats("secondary cache", *st.SecondaryCache)
+	}
+	if st.RowCache != nil {
+		c.printStats("row cache", *st.RowCache)
+	}
+
+	return true, nil
+}
+
+func (c *statsCmd) printStats(name string, s cache.Stats) {
+	c.printCache(name, s.CategoryStats)
+
+	names := make([]string, 0, len(s.Categories))
+	for name := range s.Categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c.printCache("  "+name, s.Categories[name])
+	}
+}
+
+func (c *statsCmd) printCache(name string, s cache.CategoryStats) {
+	fmt.Fprintf(c.buf, "%s: hits=%d misses=%d hit rate=%.2f inserts=%d evictions=%d entries=%d bytes=%d\n",
+		name, s.Hits, s.Misses, s.HitRate(), s.Inserts, s.Evictions, s.Len, s.SizeBytes)
+}
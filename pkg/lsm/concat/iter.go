@@ -1,25 +1,34 @@
 package concat
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/ttn-nguyen42/go-mini-lsm/internal/sst"
 	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
 )
 
+// concatIter iterates over sorted tables whose key ranges do not overlap, in key order
 type concatIter struct {
 	ssTables []sst.SortedTable
-	idx      int
-	cur      types.Iterator
+	idx      int // table of cur
+	cur      types.SeekableIterator
+	opts     []sst.ReadOption
 }
 
-func NewConcatIter(ssTables []sst.SortedTable) types.SeekableIterator {
+func NewConcatIter(ssTables []sst.SortedTable, options ...sst.ReadOption) types.SeekableIterator {
 	iter := &concatIter{
 		ssTables: ssTables,
 		idx:      0,
 		cur:      nil,
+		opts:     options,
+	}
+	if len(ssTables) > 0 {
+		if err := iter.open(0); err != nil {
+			panic(fmt.Sprintf("sorted table prematurely closed: %d", ssTables[0].Id()))
+		}
 	}
-	iter.nextTable()
 
 	return iter
 }
@@ -35,70 +44,112 @@ func (c *concatIter) Key() types.Bytes {
 	return c.cur.Key()
 }
 
-func (c *concatIter) Next() error {
+func (c *concatIter) Value() types.Bytes {
 	if c.cur == nil {
+		panic("iterator ended")
+	}
+	return c.cur.Value()
+}
+
+func (c *concatIter) Next() error {
+	if !c.HasNext() {
 		return types.ErrIterEnd
 	}
-	if c.cur.HasNext() {
-		return c.cur.Next()
-	} else {
-		return c.nextTable()
+	if err := c.cur.Next(); err != nil && !errors.Is(err, types.ErrIterEnd) {
+		return err
 	}
+
+	// the next table starts after the end of this one
+	for !c.cur.HasNext() {
+		if c.idx+1 >= len(c.ssTables) {
+			return types.ErrIterEnd
+		}
+		if err := c.open(c.idx + 1); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (c *concatIter) nextTable() error {
-	if c.idx >= len(c.ssTables) {
+func (c *concatIter) Prev() error {
+	if !c.HasNext() {
 		return types.ErrIterEnd
 	}
-
-	table := c.ssTables[c.idx]
-	var err error
-	c.cur, err = table.Scan()
-	if err != nil {
+	if err := c.cur.Prev(); err != nil && !errors.Is(err, types.ErrIterEnd) {
 		return err
 	}
-	c.idx += 1
+
+	// the previous table ends before the start of this one
+	for !c.cur.HasNext() {
+		if c.idx == 0 {
+			return types.ErrIterEnd
+		}
+		if err := c.open(c.idx - 1); err != nil {
+			return err
+		}
+		if err := c.cur.SeekToLast(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (c *concatIter) Value() types.Bytes {
-	if c.cur == nil {
-		panic("iterator ended")
+// open moves to the first key of the table at idx
+func (c *concatIter) open(idx int) error {
+	it, err := c.ssTables[idx].Scan(c.opts...)
+	if err != nil {
+		return err
 	}
-	return c.cur.Value()
+	c.cur = it
+	c.idx = idx
+	return nil
 }
 
 func (c *concatIter) Seek(idx int) error {
 	if idx >= len(c.ssTables) {
 		return fmt.Errorf("unknown sstable at idx: %d", idx)
 	}
-	table := c.ssTables[idx]
-	var err error
-	c.cur, err = table.Scan()
-	if err != nil {
+	return c.open(idx)
+}
+
+func (c *concatIter) SeekToKey(key types.Bytes) error {
+	// first table not ending before key
+	i := sort.Search(len(c.ssTables), func(i int) bool {
+		return types.BytesComparator(c.ssTables[i].LastKey(), key) >= 0
+	})
+	if i >= len(c.ssTables) {
+		c.cur = nil
+		c.idx = len(c.ssTables)
+		return nil
+	}
+	if err := c.open(i); err != nil {
 		return err
 	}
-	c.idx = idx + 1
-	return nil
+	return c.cur.SeekToKey(key)
 }
 
-func (c *concatIter) SeekToKey(key types.Bytes) error {
-	i := 0
-	for _, t := range c.ssTables {
-		if types.BytesComparator(key, t.FirstKey()) >= 0 {
-			break
-		}
-		i += 1
+func (c *concatIter) SeekToLast() error {
+	if len(c.ssTables) == 0 {
+		return nil
 	}
-	if i >= len(c.ssTables) {
-		return fmt.Errorf("key not found")
+	if err := c.open(len(c.ssTables) - 1); err != nil {
+		return err
 	}
-	table := c.ssTables[i]
-	var err error
-	c.cur, err = table.Scan()
-	if err != nil {
+	return c.cur.SeekToLast()
+}
+
+func (c *concatIter) SeekForPrev(key types.Bytes) error {
+	// last table not starting after key
+	i := sort.Search(len(c.ssTables), func(i int) bool {
+		return types.BytesComparator(c.ssTables[i].FirstKey(), key) > 0
+	}) - 1
+	if i < 0 {
+		c.cur = nil
+		c.idx = -1
+		return nil
+	}
+	if err := c.open(i); err != nil {
 		return err
 	}
-	c.idx = i
-	return nil
+	return c.cur.SeekForPrev(key)
 }
@@ -0,0 +1,111 @@
+package lsm
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/memtable"
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/sst"
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+)
+
+// tableBlockSize is the size of the data blocks of the tables written by the tree
+const tableBlockSize = 4096
+
+// Sync freezes the current memtable and writes every frozen memtable to a L0 table
+func (m *lsm) Sync() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	m.rw.RLock()
+	empty := m.currTable.LogicalSize() == 0
+	m.rw.RUnlock()
+
+	if !empty {
+		m.freeze()
+	}
+	return m.flush()
+}
+
+// flush writes the frozen memtables to L0 tables from the oldest,
+// a memtable is only dropped once its table is in L0
+func (m *lsm) flush() error {
+	for {
+		m.rw.RLock()
+		if len(m.immutTables) == 0 {
+			m.rw.RUnlock()
+			return nil
+		}
+		oldest := m.immutTables[0]
+		m.rw.RUnlock()
+
+		table, err := m.flushMemTable(oldest)
+		if err != nil {
+			return fmt.Errorf("failed to flush memtable id=%d: %s", oldest.Id(), err)
+		}
+
+		m.rw.Lock()
+		m.immutTables = m.immutTables[1:]
+		if table != nil {
+			// L0 tables are kept from the newest
+			m.l0SsTables = append([]sst.SortedTable{*table}, m.l0SsTables...)
+		}
+		m.rw.Unlock()
+
+		if table != nil {
+			log.Printf("Memtable %d flushed to table %d", oldest.Id(), table.Id())
+		}
+	}
+}
+
+// flushMemTable writes the entries of mt to a table, tombstones included so that
+// they hide the older values of their key. Returns nil for an empty memtable
+func (m *lsm) flushMemTable(mt memtable.MemTable) (*sst.SortedTable, error) {
+	it := mt.Iter()
+	defer it.Close()
+
+	if !it.HasNext() {
+		return nil, nil
+	}
+	return m.buildTable(it)
+}
+
+// buildTable writes the entries of it to a new table
+func (m *lsm) buildTable(it types.Iterator) (*sst.SortedTable, error) {
+	b := m.newTableBuilder()
+	for it.HasNext() {
+		if err := b.Add(it.Key(), it.Value()); err != nil {
+			return nil, fmt.Errorf("failed to add entry: %s", err)
+		}
+		it.Next()
+	}
+
+	id := m.sstId.Add(1)
+	table, err := b.Build(id, m.tablePath(id), m.blockCache)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build table id=%d: %s", id, err)
+	}
+	return table, nil
+}
+
+// newTableBuilder returns a builder of tables written with the options of the tree,
+// the built tables open their file through the table cache
+func (m *lsm) newTableBuilder() *sst.Builder {
+	options := []sst.BuilderOption{
+		sst.WithTableCache(m.tableCache),
+		sst.WithEncryption(m.opts.Encryption),
+		sst.WithBloomBitsPerKey(m.opts.BloomBitsPerKey),
+		sst.WithFilterPolicy(m.opts.FilterPolicy),
+		sst.WithPrefixExtractor(m.opts.PrefixExtractor),
+	}
+	if m.opts.IndexPartitionSize > 0 {
+		// the top level is searched by every lookup, only partitions go through the block cache
+		options = append(options, sst.WithPartitionedIndex(m.opts.IndexPartitionSize), sst.WithPinnedTopLevelIndex())
+	}
+	return sst.NewBuilder(tableBlockSize, options...)
+}
+
+func (m *lsm) tablePath(id int32) string {
+	return filepath.Join(m.opts.Dir, fmt.Sprintf("%06d.sst", id))
+}
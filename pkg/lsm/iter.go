@@ -1,34 +1,58 @@
 package lsm
 
 import (
+	"bytes"
+	"errors"
+
 	"github.com/ttn-nguyen42/go-mini-lsm/internal/memtable"
 	"github.com/ttn-nguyen42/go-mini-lsm/internal/sst"
 	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
 )
 
+// Iterator walks the live keys of a range in either direction and can be
+// positioned again without opening a new one. Key and Value are only
+// meaningful while Valid, Err tells whether it stopped on a failure
+type Iterator interface {
+	// Valid returns true while the iterator is on a key of the range
+	Valid() bool
+	Key() types.Bytes
+	Value() types.Bytes
+	Next()
+	Prev()
+	// Seek moves to the first key >= key, within the range
+	Seek(key types.Bytes)
+	// SeekForPrev moves to the last key <= key, within the range
+	SeekForPrev(key types.Bytes)
+	SeekToFirst()
+	SeekToLast()
+	// Err returns the error that stopped the iterator, nil when it only ran out of keys
+	Err() error
+	Close()
+}
+
 type lsmIter struct {
 	memTableIters  []types.ClosableIterator
 	l0SsTableIters []types.Iterator
 	leveledIters   []types.Iterator
-	done           bool
 	upper          types.Bound[types.Bytes]
 	lower          types.Bound[types.Bytes]
+	err            error
 
-	mergeIter types.Iterator
+	mergeIter types.ReverseIterator
 }
 
-func NewIter(tables []memtable.MemTable, l0SsTables []sst.SortedTable, leveledSsTables [][]sst.SortedTable, lower types.Bound[types.Bytes], upper types.Bound[types.Bytes]) types.ClosableIterator {
+func NewIter(tables []memtable.MemTable, l0SsTables []sst.SortedTable, leveledSsTables [][]sst.SortedTable, lower types.Bound[types.Bytes], upper types.Bound[types.Bytes], options ...sst.ReadOption) Iterator {
 
 	lsmIter := &lsmIter{
 		memTableIters:  SelectMemTableItersInRange(tables, lower, upper),
-		l0SsTableIters: SelectSstItersInRange(l0SsTables, lower, upper),
-		leveledIters:   SelectLeveledSstInRange(leveledSsTables, lower, upper),
-		done:           true,
+		l0SsTableIters: SelectSstItersInRange(l0SsTables, lower, upper, options...),
+		leveledIters:   SelectLeveledSstInRange(leveledSsTables, lower, upper, options...),
 		lower:          lower,
 		upper:          upper,
 	}
 	lsmIter.initIters()
-	lsmIter.skipToNonDeleted()
+	lsmIter.skipBeforeLower()
+	lsmIter.fail(lsmIter.skipToNonDeleted())
 
 	return lsmIter
 }
@@ -42,8 +66,8 @@ func (l *lsmIter) initIters() {
 	l0SstIter := types.NewMergeIter(l.l0SsTableIters...)
 	leveledIter := types.NewMergeIter(l.leveledIters...)
 
-	memTableL0Iter := types.NewTwoWayIter(memTableIter, l0SstIter)
-	l.mergeIter = types.NewTwoWayIter(memTableL0Iter, leveledIter)
+	// the first iterator wins on equal keys, tiers go from the newest
+	l.mergeIter = types.NewMergeIter(memTableIter, l0SstIter, leveledIter)
 }
 
 func (l *lsmIter) Close() {
@@ -52,8 +76,24 @@ func (l *lsmIter) Close() {
 	}
 }
 
-func (l *lsmIter) HasNext() bool {
-	return l.mergeIter.HasNext()
+func (l *lsmIter) Valid() bool {
+	return l.err == nil && l.inRange()
+}
+
+func (l *lsmIter) Err() error {
+	return l.err
+}
+
+// fail keeps the first error that is not the end of the iterator
+func (l *lsmIter) fail(err error) {
+	if err != nil && l.err == nil && !errors.Is(err, types.ErrIterEnd) {
+		l.err = err
+	}
+}
+
+// inRange also checks the bounds, sorted tables are not bounded by the range
+func (l *lsmIter) inRange() bool {
+	return l.mergeIter.HasNext() && types.IsWithinBoundary(l.lower, l.upper, l.mergeIter.Key(), types.BytesComparator)
 }
 
 func (l *lsmIter) Key() types.Bytes {
@@ -64,31 +104,130 @@ func (l *lsmIter) Value() types.Bytes {
 	return l.mergeIter.Value()
 }
 
-func (l *lsmIter) Next() error {
+func (l *lsmIter) Next() {
+	if !l.Valid() {
+		return
+	}
 	if err := l.next(); err != nil {
-		return err
+		l.fail(err)
+		return
 	}
 
-	if err := l.skipToNonDeleted(); err != nil {
+	l.fail(l.skipToNonDeleted())
+}
+
+func (l *lsmIter) next() error {
+	if err := l.mergeIter.Next(); err != nil && !errors.Is(err, types.ErrIterEnd) {
 		return err
 	}
 
+	if !l.inRange() {
+		return types.ErrIterEnd
+	}
+
 	return nil
 }
 
-func (l *lsmIter) next() error {
-	l.mergeIter.Next()
+func (l *lsmIter) Prev() {
+	if !l.Valid() {
+		return
+	}
+	if err := l.prev(); err != nil {
+		l.fail(err)
+		return
+	}
+
+	l.fail(l.skipToNonDeletedBackward())
+}
+
+func (l *lsmIter) prev() error {
+	if err := l.mergeIter.Prev(); err != nil && !errors.Is(err, types.ErrIterEnd) {
+		return err
+	}
 
-	if !l.mergeIter.HasNext() {
-		l.done = true
+	if !l.inRange() {
 		return types.ErrIterEnd
 	}
 
 	return nil
 }
 
+func (l *lsmIter) SeekToFirst() {
+	l.seekFirst(l.lower)
+}
+
+func (l *lsmIter) SeekToLast() {
+	l.seekLast(l.upper)
+}
+
+func (l *lsmIter) Seek(key types.Bytes) {
+	from := types.Include(key)
+	if l.lower.IsBefore(key, types.BytesComparator) {
+		from = l.lower
+	}
+	l.seekFirst(from)
+}
+
+func (l *lsmIter) SeekForPrev(key types.Bytes) {
+	to := types.Include(key)
+	if l.upper.IsAfter(key, types.BytesComparator) {
+		to = l.upper
+	}
+	l.seekLast(to)
+}
+
+// seekFirst repositions every child iterator, which also clears a previous error
+func (l *lsmIter) seekFirst(from types.Bound[types.Bytes]) {
+	l.err = nil
+	if err := l.mergeIter.SeekToKey(from.Data()); err != nil {
+		l.fail(err)
+		return
+	}
+	if !from.IsIncluded() && !from.IsUnbounded() && l.mergeIter.HasNext() && bytes.Equal(l.mergeIter.Key(), from.Data()) {
+		l.fail(l.mergeIter.Next())
+	}
+
+	l.fail(l.skipToNonDeleted())
+}
+
+func (l *lsmIter) seekLast(to types.Bound[types.Bytes]) {
+	l.err = nil
+	var err error
+	if to.IsUnbounded() {
+		err = l.mergeIter.SeekToLast()
+	} else {
+		err = l.mergeIter.SeekForPrev(to.Data())
+	}
+	if err != nil {
+		l.fail(err)
+		return
+	}
+	if !to.IsIncluded() && !to.IsUnbounded() && l.mergeIter.HasNext() && bytes.Equal(l.mergeIter.Key(), to.Data()) {
+		l.fail(l.mergeIter.Prev())
+	}
+
+	l.fail(l.skipToNonDeletedBackward())
+}
+
+// skipBeforeLower moves sorted tables seeked to the data of an excluded lower bound past it
+func (l *lsmIter) skipBeforeLower() {
+	for l.err == nil && l.mergeIter.HasNext() && l.lower.IsBefore(l.mergeIter.Key(), types.BytesComparator) {
+		l.fail(l.mergeIter.Next())
+	}
+}
+
+func (l *lsmIter) skipToNonDeletedBackward() error {
+	for l.inRange() && l.Value().Size() == 0 {
+		if err := l.prev(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (l *lsmIter) skipToNonDeleted() error {
-	for l.HasNext() && l.Value().Size() == 0 {
+	for l.inRange() && l.Value().Size() == 0 {
 		if err := l.next(); err != nil {
 			return err
 		}
@@ -0,0 +1,225 @@
+package lsm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+)
+
+// newIterTestLSM spreads keys over every tier, live keys are
+// a=l1 b=new c=l0 d=l0 g=old h=new, e and f are deleted
+func newIterTestLSM(t *testing.T) *lsm {
+	m := newTestLSM(t)
+
+	addLevel(m, addTable(t, m, "a", "l1", "c", "l1", "e", "l1", "g", "l1"))
+
+	m.Put(types.Bytes("c"), types.Bytes("l0"))
+	m.Put(types.Bytes("d"), types.Bytes("l0"))
+	m.Delete(types.Bytes("e"))
+	assert.NoError(t, m.Sync())
+
+	m.Put(types.Bytes("b"), types.Bytes("old"))
+	m.Put(types.Bytes("f"), types.Bytes("old"))
+	m.Put(types.Bytes("g"), types.Bytes("old"))
+	m.freeze()
+
+	m.Put(types.Bytes("b"), types.Bytes("new"))
+	m.Delete(types.Bytes("f"))
+	m.Put(types.Bytes("h"), types.Bytes("new"))
+	return m
+}
+
+func newTestIter(t *testing.T, m *lsm, lower types.Bound[types.Bytes], upper types.Bound[types.Bytes]) Iterator {
+	it := m.Scan(lower, upper)
+	t.Cleanup(it.Close)
+	return it
+}
+
+func assertAt(t *testing.T, it Iterator, key string, val string) {
+	t.Helper()
+	if !assert.True(t, it.Valid(), "expected key %s", key) {
+		return
+	}
+	assert.Equal(t, key, string(it.Key()))
+	assert.Equal(t, val, string(it.Value()))
+}
+
+func assertDone(t *testing.T, it Iterator) {
+	t.Helper()
+	assert.False(t, it.Valid())
+	assert.NoError(t, it.Err())
+}
+
+// forward returns key=value pairs from the current position to the end
+func forward(it Iterator) []string {
+	res := make([]string, 0)
+	for ; it.Valid(); it.Next() {
+		res = append(res, fmt.Sprintf("%s=%s", it.Key(), it.Value()))
+	}
+	return res
+}
+
+func backward(it Iterator) []string {
+	res := make([]string, 0)
+	for ; it.Valid(); it.Prev() {
+		res = append(res, fmt.Sprintf("%s=%s", it.Key(), it.Value()))
+	}
+	return res
+}
+
+func TestIterNewestTierWins(t *testing.T) {
+	m := newIterTestLSM(t)
+	it := newTestIter(t, m, types.Unbounded[types.Bytes](), types.Unbounded[types.Bytes]())
+
+	assert.Equal(t, []string{"a=l1", "b=new", "c=l0", "d=l0", "g=old", "h=new"}, forward(it))
+	assertDone(t, it)
+
+	it.SeekToLast()
+	assert.Equal(t, []string{"h=new", "g=old", "d=l0", "c=l0", "b=new", "a=l1"}, backward(it))
+	assertDone(t, it)
+
+	it.SeekToFirst()
+	assertAt(t, it, "a", "l1")
+}
+
+func TestIterSeekTombstone(t *testing.T) {
+	m := newIterTestLSM(t)
+	it := newTestIter(t, m, types.Unbounded[types.Bytes](), types.Unbounded[types.Bytes]())
+
+	// e is deleted in L0, f in the current memtable
+	it.Seek(types.Bytes("e"))
+	assertAt(t, it, "g", "old")
+	it.Seek(types.Bytes("f"))
+	assertAt(t, it, "g", "old")
+
+	it.SeekForPrev(types.Bytes("f"))
+	assertAt(t, it, "d", "l0")
+	it.SeekForPrev(types.Bytes("e"))
+	assertAt(t, it, "d", "l0")
+
+	// between keys
+	it.Seek(types.Bytes("bb"))
+	assertAt(t, it, "c", "l0")
+	it.SeekForPrev(types.Bytes("bb"))
+	assertAt(t, it, "b", "new")
+
+	it.Seek(types.Bytes("z"))
+	assertDone(t, it)
+	it.SeekForPrev(types.Bytes("0"))
+	assertDone(t, it)
+}
+
+func TestIterSeekOutsideBounds(t *testing.T) {
+	m := newIterTestLSM(t)
+
+	it := newTestIter(t, m, types.Include(types.Bytes("b")), types.Exclude(types.Bytes("g")))
+	assert.Equal(t, []string{"b=new", "c=l0", "d=l0"}, forward(it))
+
+	// seeks before the range land on its first key, after it on nothing
+	it.Seek(types.Bytes("a"))
+	assertAt(t, it, "b", "new")
+	it.Seek(types.Bytes("g"))
+	assertDone(t, it)
+	it.Seek(types.Bytes("z"))
+	assertDone(t, it)
+
+	it.SeekForPrev(types.Bytes("z"))
+	assertAt(t, it, "d", "l0")
+	it.SeekForPrev(types.Bytes("a"))
+	assertDone(t, it)
+
+	it.SeekToFirst()
+	assertAt(t, it, "b", "new")
+	it.SeekToLast()
+	assertAt(t, it, "d", "l0")
+	it.Next()
+	assertDone(t, it)
+
+	it = newTestIter(t, m, types.Exclude(types.Bytes("b")), types.Include(types.Bytes("g")))
+	assert.Equal(t, []string{"c=l0", "d=l0", "g=old"}, forward(it))
+
+	it.Seek(types.Bytes("b"))
+	assertAt(t, it, "c", "l0")
+	it.SeekToLast()
+	assertAt(t, it, "g", "old")
+	it.SeekForPrev(types.Bytes("h"))
+	assertAt(t, it, "g", "old")
+	it.SeekToFirst()
+	assertAt(t, it, "c", "l0")
+	it.Prev()
+	assertDone(t, it)
+
+	// a range holding only deleted keys
+	it = newTestIter(t, m, types.Include(types.Bytes("e")), types.Include(types.Bytes("f")))
+	assertDone(t, it)
+	it.SeekToFirst()
+	assertDone(t, it)
+	it.SeekToLast()
+	assertDone(t, it)
+}
+
+func TestIterNextThenPrev(t *testing.T) {
+	m := newIterTestLSM(t)
+	it := newTestIter(t, m, types.Unbounded[types.Bytes](), types.Unbounded[types.Bytes]())
+
+	it.Seek(types.Bytes("c"))
+	it.Next()
+	assertAt(t, it, "d", "l0")
+	it.Prev()
+	assertAt(t, it, "c", "l0")
+	it.Prev()
+	assertAt(t, it, "b", "new")
+	it.Next()
+	assertAt(t, it, "c", "l0")
+
+	// across the deleted keys
+	it.Seek(types.Bytes("d"))
+	it.Next()
+	assertAt(t, it, "g", "old")
+	it.Prev()
+	assertAt(t, it, "d", "l0")
+	it.Next()
+	assertAt(t, it, "g", "old")
+
+	// moving does not revive an iterator past its end
+	it.SeekToLast()
+	it.Next()
+	assertDone(t, it)
+	it.Prev()
+	assertDone(t, it)
+}
+
+func TestIterErrAfterChildFails(t *testing.T) {
+	m := newTestLSM(t)
+
+	// enough data for the table to span several blocks
+	value := bytes.Repeat([]byte("v"), 1000)
+	for i := range 20 {
+		m.Put(types.Bytes(fmt.Sprintf("k%02d", i)), value)
+	}
+	assert.NoError(t, m.Sync())
+	assert.Greater(t, m.l0SsTables[0].NumBlocks(), 1)
+
+	it := newTestIter(t, m, types.Unbounded[types.Bytes](), types.Unbounded[types.Bytes]())
+	assertAt(t, it, "k00", string(value))
+	assert.NoError(t, it.Err())
+
+	// the next blocks can not be read anymore
+	table := m.l0SsTables[0]
+	assert.NoError(t, os.Remove(m.tablePath(table.Id())))
+	assert.NoError(t, table.File().Close())
+
+	n := len(forward(it))
+	assert.Less(t, n, 20)
+	assert.False(t, it.Valid())
+	assert.Error(t, it.Err())
+
+	// a stopped iterator stays stopped
+	it.Next()
+	assert.False(t, it.Valid())
+	assert.Error(t, it.Err())
+}
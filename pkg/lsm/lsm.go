@@ -1,6 +1,8 @@
 package lsm
 
 import (
+	"bytes"
+	"fmt"
 	"log"
 	"sync"
 	"sync/atomic"
@@ -9,16 +11,30 @@ import (
 	"github.com/ttn-nguyen42/go-mini-lsm/internal/sst"
 	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
 	"github.com/ttn-nguyen42/go-mini-lsm/internal/utils"
-	"github.com/ttn-nguyen42/go-mini-lsm/pkg/lsm/concat"
+	"github.com/ttn-nguyen42/go-mini-lsm/pkg/cache"
 )
 
 type LSM interface {
 	Put(key types.Bytes, value types.Bytes)
 	Delete(key types.Bytes)
 	Get(key types.Bytes) (types.Bytes, bool, error)
-	Sync()
-	Scan(lower types.Bound[types.Bytes], upper types.Bound[types.Bytes]) types.Iterator
+	MultiGet(keys []types.Bytes) ([]types.Bytes, []bool, error)
+	// Sync writes the keys held in memory to L0 tables
+	Sync() error
+	Scan(lower types.Bound[types.Bytes], upper types.Bound[types.Bytes], options ...ReadOption) Iterator
 	Transaction()
+	Stats() Stats
+}
+
+// Stats reports the activity of the tree
+type Stats struct {
+	BlockCache     cache.Stats  // broken down by kind of block, "data" and "index"
+	SecondaryCache *cache.Stats // nil without a secondary cache
+	RowCache       *cache.Stats // nil without a row cache
+
+	MemTables           int // the current memtable and the frozen ones
+	MemTableSize        int // bytes of memory held by memtables
+	MemTableLogicalSize int // bytes of keys and values held by memtables
 }
 
 type lsm struct {
@@ -34,6 +50,9 @@ type lsm struct {
 	ssTables    map[int32][]sst.SortedTable
 	iterCount   int
 	blockCache  sst.BlockCache
+	secondary   *sst.SecondaryBlockCache
+	rowCache    sst.RowCache
+	tableCache  *sst.TableCache
 }
 
 func New(options ...Option) (LSM, error) {
@@ -52,7 +71,7 @@ func newInit(options ...Option) *lsm {
 		memTableId:  atomic.Int32{},
 		sstId:       atomic.Int32{},
 		immutTables: make([]memtable.MemTable, 0),
-		currTable:   memtable.New(0),
+		currTable:   opts.MemTableFactory(0),
 		l0SsTables:  make([]sst.SortedTable, 0),
 		state:       sync.Mutex{},
 		rw:          sync.RWMutex{},
@@ -80,78 +99,132 @@ func (m *lsm) Get(key types.Bytes) (types.Bytes, bool, error) {
 	return m.get(key)
 }
 
+// getFromMemtables looks key up from the newest memtable to the oldest
 func (m *lsm) getFromMemtables(key types.Bytes) (types.Bytes, bool) {
 	if val, found := m.currTable.Get(key); found {
 		return val, true
 	}
-	for _, table := range m.immutTables {
-		if val, found := table.Get(key); found {
+	for i := len(m.immutTables) - 1; i >= 0; i -= 1 {
+		if val, found := m.immutTables[i].Get(key); found {
 			return val, true
 		}
 	}
 	return nil, false
 }
 
-func (m *lsm) getL0Iterators(key types.Bytes) ([]types.Iterator, error) {
-	l0iters := make([]types.Iterator, 0, len(m.l0SsTables))
-	for _, table := range m.l0SsTables {
-		if types.IsWithinRange(table.FirstKey(), table.LastKey(), key, types.BytesComparator) {
-			if table.Contains(key) {
-				iter, err := table.Scan()
-				if err != nil {
-					return nil, err
-				}
-				_ = iter.SeekToKey(key)
-				l0iters = append(l0iters, iter)
-			}
+// get stops at the first hit: memtables, then L0 tables from the newest,
+// then at most one table per level since tables of a level do not overlap.
+// A hit on a tombstone means the key was deleted
+func (m *lsm) get(key types.Bytes) (types.Bytes, bool, error) {
+	if val, found := m.getFromMemtables(key); found {
+		return live(val)
+	}
+
+	for i := range m.l0SsTables {
+		table := &m.l0SsTables[i]
+		val, found, err := m.tableGet(table, key)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get from table id=%d: %s", table.Id(), err)
+		}
+		if found {
+			return live(val)
 		}
 	}
-	return l0iters, nil
-}
 
-func (m *lsm) getLevelIterators(key types.Bytes) ([]types.Iterator, error) {
-	levelIters := make([]types.Iterator, 0, len(m.sstLevels))
 	for _, levelIds := range m.sstLevels {
-		levelTables := make([]sst.SortedTable, 0, len(levelIds))
-		for _, id := range levelIds {
-			for _, table := range m.ssTables[id] {
-				if types.IsWithinRange(table.FirstKey(), table.LastKey(), key, types.BytesComparator) {
-					if table.Contains(key) {
-						levelTables = append(levelTables, table)
-					}
-				}
-			}
+		table, ok := m.levelTable(levelIds, key)
+		if !ok {
+			continue
 		}
-		if len(levelTables) > 0 {
-			it := concat.NewConcatIter(levelTables)
-			_ = it.SeekToKey(key)
-			levelIters = append(levelIters, it)
+
+		val, found, err := m.tableGet(table, key)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get from table id=%d: %s", table.Id(), err)
+		}
+		if found {
+			return live(val)
 		}
 	}
-	return levelIters, nil
+
+	return nil, false, nil
 }
 
-func (m *lsm) get(key types.Bytes) (types.Bytes, bool, error) {
-	if val, found := m.getFromMemtables(key); found {
-		return val, true, nil
+// live reports a tombstone as a missing key
+func live(val types.Bytes) (types.Bytes, bool, error) {
+	if val.Size() == 0 {
+		return nil, false, nil
+	}
+	return val, true, nil
+}
+
+// tableGet looks key up in the row cache before reading the table
+func (m *lsm) tableGet(table *sst.SortedTable, key types.Bytes) (types.Bytes, bool, error) {
+	if m.rowCache == nil {
+		return table.Get(key)
 	}
 
-	l0iters, err := m.getL0Iterators(key)
-	if err != nil {
-		return nil, false, err
+	rk := sst.NewRowKey(table.Id(), key)
+	if row, ok := m.rowCache.Get(rk); ok {
+		return row.Value, true, nil
+	}
+
+	val, found, err := table.Get(key)
+	if err != nil || !found {
+		return val, found, err
+	}
+	row := sst.NewRow(val)
+	m.rowCache.Set(rk, row)
+	return row.Value, true, nil
+}
+
+// tableMultiGet looks sorted keys up in the row cache, only the keys it misses are read from the table
+func (m *lsm) tableMultiGet(table *sst.SortedTable, keys []types.Bytes) ([]types.Bytes, []bool, error) {
+	if m.rowCache == nil {
+		return table.MultiGet(keys)
 	}
-	l0MergedIter := types.NewMergeIter(l0iters...)
 
-	levelIters, err := m.getLevelIterators(key)
+	vals := make([]types.Bytes, len(keys))
+	found := make([]bool, len(keys))
+	missed := make([]int, 0, len(keys))
+	missedKeys := make([]types.Bytes, 0, len(keys))
+	for i, key := range keys {
+		if row, ok := m.rowCache.Get(sst.NewRowKey(table.Id(), key)); ok {
+			vals[i], found[i] = row.Value, true
+			continue
+		}
+		missed = append(missed, i)
+		missedKeys = append(missedKeys, key)
+	}
+	if len(missed) == 0 {
+		return vals, found, nil
+	}
+
+	missedVals, missedFound, err := table.MultiGet(missedKeys)
 	if err != nil {
-		return nil, false, err
+		return nil, nil, err
 	}
-	mergedIter := types.NewTwoWayIter(l0MergedIter, types.NewMergeIter(levelIters...))
-	if mergedIter.HasNext() && types.BytesComparator(mergedIter.Key(), key) == 0 {
-		return mergedIter.Value(), true, nil
+	for j, i := range missed {
+		if !missedFound[j] {
+			continue
+		}
+		row := sst.NewRow(missedVals[j])
+		m.rowCache.Set(sst.NewRowKey(table.Id(), keys[i]), row)
+		vals[i], found[i] = row.Value, true
 	}
+	return vals, found, nil
+}
 
-	return nil, false, nil
+// levelTable returns the table of a level whose key range holds key
+func (m *lsm) levelTable(levelIds []int32, key types.Bytes) (*sst.SortedTable, bool) {
+	for _, id := range levelIds {
+		tables := m.ssTables[id]
+		for i := range tables {
+			if types.IsWithinRange(tables[i].FirstKey(), tables[i].LastKey(), key, types.BytesComparator) {
+				return &tables[i], true
+			}
+		}
+	}
+	return nil, false
 }
 
 func (m *lsm) Put(key types.Bytes, value types.Bytes) {
@@ -189,36 +262,71 @@ func (m *lsm) tryFreeze(tableSize int) {
 }
 
 func (m *lsm) freeze() {
-	mt := memtable.New(int(m.memTableId.Add(1)))
+	mt := m.opts.MemTableFactory(int(m.memTableId.Add(1)))
 	m.rw.Lock()
-	defer m.rw.Unlock()
 
-	m.immutTables = append(m.immutTables, m.currTable)
+	frozen := m.currTable
+	m.immutTables = append(m.immutTables, frozen)
 	m.currTable = mt
+	immutCount := len(m.immutTables)
+	m.rw.Unlock()
 
-	log.Printf("Memtable %d frozen, total immutable tables: %d", m.currTable.Id(), len(m.immutTables))
-}
+	// no writer holds the frozen memtable anymore, freezing it only blocks its own readers
+	if f, ok := frozen.(memtable.Freezer); ok {
+		f.Freeze()
+	}
 
-func (m *lsm) Sync() {
-	panic("unimplemented")
+	log.Printf("Memtable %d frozen, total immutable tables: %d", frozen.Id(), immutCount)
 }
 
 func (m *lsm) Transaction() {
 	panic("unimplemented")
 }
 
-func (m *lsm) Scan(lower types.Bound[types.Bytes], upper types.Bound[types.Bytes]) types.Iterator {
+func (m *lsm) Stats() Stats {
+	st := Stats{
+		BlockCache: m.blockCache.Stats(),
+	}
+	if m.secondary != nil {
+		secondary := m.secondary.Stats()
+		st.SecondaryCache = &secondary
+	}
+	if m.rowCache != nil {
+		rows := m.rowCache.Stats()
+		st.RowCache = &rows
+	}
+
 	m.rw.RLock()
 	defer m.rw.RUnlock()
+	for _, mt := range append([]memtable.MemTable{m.currTable}, m.immutTables...) {
+		st.MemTables += 1
+		st.MemTableSize += mt.Size()
+		st.MemTableLogicalSize += mt.LogicalSize()
+	}
+	return st
+}
 
-	return m.scan(lower, upper)
+func (m *lsm) Scan(lower types.Bound[types.Bytes], upper types.Bound[types.Bytes], options ...ReadOption) Iterator {
+	m.rw.RLock()
+	defer m.rw.RUnlock()
+
+	return m.scan(lower, upper, getReadOptions(options...))
 }
 
-func (m *lsm) scan(lower types.Bound[types.Bytes], upper types.Bound[types.Bytes]) types.Iterator {
+func (m *lsm) scan(lower types.Bound[types.Bytes], upper types.Bound[types.Bytes], ro *ReadOptions) Iterator {
+	// the first iterator wins on equal keys, memtables go from the newest
 	memTables := make([]memtable.MemTable, 0, len(m.immutTables)+1)
-
-	memTables = append(memTables, m.immutTables...)
 	memTables = append(memTables, m.currTable)
+	for i := len(m.immutTables) - 1; i >= 0; i -= 1 {
+		memTables = append(memTables, m.immutTables[i])
+	}
+
+	prefix, isPrefixScan := m.scanPrefix(lower, upper)
+
+	l0Tables := m.l0SsTables
+	if isPrefixScan {
+		l0Tables = m.selectWithPrefix(l0Tables, prefix)
+	}
 
 	tablesByLevel := make([][]sst.SortedTable, 0, len(m.sstLevels))
 	for _, lvlTableIds := range m.sstLevels {
@@ -228,18 +336,74 @@ func (m *lsm) scan(lower types.Bound[types.Bytes], upper types.Bound[types.Bytes
 			table := m.ssTables[id]
 			tableOnLvl = append(tableOnLvl, table...)
 		}
+		if isPrefixScan {
+			tableOnLvl = m.selectWithPrefix(tableOnLvl, prefix)
+		}
 
 		tablesByLevel = append(tablesByLevel, tableOnLvl)
 	}
 
-	return NewIter(memTables, m.l0SsTables, tablesByLevel, lower, upper)
+	return NewIter(memTables, l0Tables, tablesByLevel, lower, upper, sst.FillCache(ro.FillCache))
+}
+
+// scanPrefix returns the prefix shared by every key of the range, if there is one
+func (m *lsm) scanPrefix(lower types.Bound[types.Bytes], upper types.Bound[types.Bytes]) (types.Bytes, bool) {
+	pe := m.opts.PrefixExtractor
+	if pe == nil {
+		return nil, false
+	}
+
+	if lower.IsUnbounded() {
+		return nil, false
+	}
+
+	prefix, ok := pe.Transform(lower.Data())
+	if !ok {
+		return nil, false
+	}
+
+	// the range ends with the prefix when nothing sorts after its keys
+	_, end := types.PrefixRange(prefix)
+	if upper.IsUnbounded() {
+		return prefix, end.IsUnbounded()
+	}
+
+	// keys sharing a prefix are contiguous, so the range is within
+	// the prefix when its upper end is too
+	if upperPrefix, ok := pe.Transform(upper.Data()); ok && bytes.Equal(prefix, upperPrefix) {
+		return prefix, true
+	}
+	if !end.IsUnbounded() && !upper.IsIncluded() && bytes.Equal(upper.Data(), end.Data()) {
+		return prefix, true
+	}
+
+	return nil, false
+}
+
+func (m *lsm) selectWithPrefix(tables []sst.SortedTable, prefix types.Bytes) []sst.SortedTable {
+	selected := make([]sst.SortedTable, 0, len(tables))
+	for _, table := range tables {
+		if table.MayContainPrefix(m.opts.PrefixExtractor, prefix) {
+			selected = append(selected, table)
+		}
+	}
+	return selected
 }
 
 func (m *lsm) open() error {
 	if err := utils.ForceDirExists(m.opts.Dir); err != nil {
 		return err
 	}
-	m.blockCache = sst.NewBlockCache(m.opts.BlockCacheSize)
+	if m.opts.SecondaryCacheSize > 0 {
+		m.secondary = sst.NewSecondaryBlockCache(m.opts.SecondaryCacheSize)
+		m.blockCache = sst.NewTieredBlockCache(m.opts.BlockCacheSize, m.secondary, cache.WithPolicy(m.opts.BlockCachePolicy))
+	} else {
+		m.blockCache = sst.NewBlockCache(m.opts.BlockCacheSize, cache.WithPolicy(m.opts.BlockCachePolicy))
+	}
+	m.tableCache = sst.NewTableCache(m.opts.MaxOpenFiles)
+	if m.opts.RowCacheSize > 0 {
+		m.rowCache = sst.NewRowCache(m.opts.RowCacheSize)
+	}
 
 	return nil
 }
@@ -0,0 +1,362 @@
+package lsm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/sst"
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+)
+
+func newTestLSM(t *testing.T, options ...Option) *lsm {
+	m, err := New(append([]Option{Dir(t.TempDir())}, options...)...)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		m.(*lsm).Close()
+	})
+	return m.(*lsm)
+}
+
+func TestSyncFlushesToL0(t *testing.T) {
+	m := newTestLSM(t)
+
+	m.Put(types.Bytes("a"), types.Bytes("old"))
+	m.Put(types.Bytes("b"), types.Bytes("old"))
+	assert.NoError(t, m.Sync())
+	m.Put(types.Bytes("a"), types.Bytes("new"))
+	m.Delete(types.Bytes("b"))
+	m.freeze()
+	m.Put(types.Bytes("c"), types.Bytes("new"))
+	assert.NoError(t, m.Sync())
+
+	// frozen memtables are flushed from the oldest, L0 is kept from the newest
+	assert.Len(t, m.l0SsTables, 3)
+	assert.Empty(t, m.immutTables)
+	assert.Equal(t, 0, m.currTable.LogicalSize())
+
+	val, found, err := m.Get(types.Bytes("a"))
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "new", string(val))
+
+	// tombstones are flushed to hide the older values
+	val, found, err = m.l0SsTables[1].Get(types.Bytes("b"))
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Empty(t, val)
+
+	// nothing left to flush
+	assert.NoError(t, m.Sync())
+	assert.Len(t, m.l0SsTables, 3)
+}
+
+func TestSyncFlushesThroughTableCache(t *testing.T) {
+	m := newTestLSM(t, MaxOpenFiles(2))
+
+	for table := range 4 {
+		for i := range 10 {
+			m.Put(types.Bytes(fmt.Sprintf("t%d-k%02d", table, i)), types.Bytes(fmt.Sprintf("v%d", i)))
+		}
+		assert.NoError(t, m.Sync())
+	}
+	assert.Len(t, m.l0SsTables, 4)
+	assert.Empty(t, m.immutTables)
+
+	for table := range 4 {
+		val, found, err := m.Get(types.Bytes(fmt.Sprintf("t%d-k05", table)))
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "v5", string(val))
+	}
+	assert.LessOrEqual(t, m.tableCache.OpenFiles(), 2)
+
+	// nothing left to flush
+	assert.NoError(t, m.Sync())
+	assert.Len(t, m.l0SsTables, 4)
+}
+
+func TestSyncEncryptsTables(t *testing.T) {
+	keyring := sst.NewKeyring()
+	assert.NoError(t, keyring.Rotate(1, bytes.Repeat([]byte{1}, 32)))
+	m := newTestLSM(t, Encryption(sst.NewAESCTRProvider(keyring)))
+
+	m.Put(types.Bytes("a"), types.Bytes("secret-one"))
+	assert.NoError(t, m.Sync())
+
+	// new tables use the new key, older ones stay readable
+	assert.NoError(t, keyring.Rotate(2, bytes.Repeat([]byte{2}, 32)))
+	m.Put(types.Bytes("b"), types.Bytes("secret-two"))
+	assert.NoError(t, m.Sync())
+
+	for key, want := range map[string]string{"a": "secret-one", "b": "secret-two"} {
+		val, found, err := m.Get(types.Bytes(key))
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, want, string(val))
+	}
+
+	for _, table := range m.l0SsTables {
+		data, err := os.ReadFile(m.tablePath(table.Id()))
+		assert.NoError(t, err)
+		assert.False(t, bytes.Contains(data, []byte("secret")))
+	}
+}
+
+func TestSyncBloomOptions(t *testing.T) {
+	tableSize := func(bitsPerKey int) int64 {
+		m := newTestLSM(t, BloomBitsPerKey(bitsPerKey), PrefixExtractor(sst.FixedPrefix(2)))
+		for i := range 1000 {
+			m.Put(types.Bytes(fmt.Sprintf("k%04d", i)), types.Bytes("v"))
+		}
+		assert.NoError(t, m.Sync())
+		assert.Len(t, m.l0SsTables, 1)
+
+		table := m.l0SsTables[0]
+		assert.Equal(t, "fixed:2", table.Properties().PrefixExtractor)
+		assert.False(t, table.MayContainPrefix(sst.FixedPrefix(2), types.Bytes("zz")))
+
+		info, err := os.Stat(m.tablePath(table.Id()))
+		assert.NoError(t, err)
+		return info.Size()
+	}
+
+	// 1000 keys at 2 and 20 bits per key differ by about 2KB of filter
+	assert.Greater(t, tableSize(20)-tableSize(2), int64(1500))
+}
+
+func TestSyncFilterPolicy(t *testing.T) {
+	policies := []sst.FilterPolicy{nil, sst.NewRibbonPolicy(10), sst.NewBlockedBloomPolicy(10)}
+	for _, policy := range policies {
+		m := newTestLSM(t, FilterPolicy(policy))
+		for i := range 100 {
+			m.Put(types.Bytes(fmt.Sprintf("k%04d", i)), types.Bytes("v"))
+		}
+		assert.NoError(t, m.Sync())
+
+		// the tree leaves the default bloom filter to the builder
+		want := sst.NewBloomPolicy(10).Name()
+		if policy != nil {
+			want = policy.Name()
+		}
+		assert.Equal(t, want, m.l0SsTables[0].Properties().FilterPolicy)
+
+		for i := range 100 {
+			val, found, err := m.Get(types.Bytes(fmt.Sprintf("k%04d", i)))
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, types.Bytes("v"), val)
+		}
+	}
+}
+
+// addTable builds a table of sorted key, value pairs, an empty value is a tombstone
+func addTable(t *testing.T, m *lsm, kvs ...string) sst.SortedTable {
+	b := m.newTableBuilder()
+	for i := 0; i < len(kvs); i += 2 {
+		assert.NoError(t, b.Add(types.Bytes(kvs[i]), types.Bytes(kvs[i+1])))
+	}
+	id := m.sstId.Add(1)
+	table, err := b.Build(id, m.tablePath(id), m.blockCache)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		table.Close()
+	})
+	return *table
+}
+
+// addLevel appends a level of non overlapping tables below the existing ones
+func addLevel(m *lsm, tables ...sst.SortedTable) {
+	ids := make([]int32, 0, len(tables))
+	for _, table := range tables {
+		ids = append(ids, table.Id())
+		m.ssTables[table.Id()] = []sst.SortedTable{table}
+	}
+	m.sstLevels = append(m.sstLevels, ids)
+}
+
+func assertGet(t *testing.T, m *lsm, key string, want string, wantFound bool) {
+	t.Helper()
+	val, found, err := m.Get(types.Bytes(key))
+	assert.NoError(t, err)
+	assert.Equal(t, wantFound, found, "key %s", key)
+	if wantFound {
+		assert.Equal(t, want, string(val), "key %s", key)
+	} else {
+		assert.Nil(t, val, "key %s", key)
+	}
+}
+
+func TestGetTierOrder(t *testing.T) {
+	m := newTestLSM(t)
+
+	// L1 has a gap between its tables, L2 fills it
+	addLevel(m,
+		addTable(t, m, "a", "l1", "c", "l1", "d", ""),
+		addTable(t, m, "p", "l1", "s", "l1"),
+	)
+	addLevel(m,
+		addTable(t, m, "a", "l2", "c", "l2", "d", "l2", "f", "l2", "h", "l2", "n", "l2"),
+	)
+
+	// L0 from the oldest
+	m.Put(types.Bytes("b"), types.Bytes("l0-old"))
+	m.Put(types.Bytes("c"), types.Bytes("l0-old"))
+	m.Put(types.Bytes("e"), types.Bytes("l0-old"))
+	assert.NoError(t, m.Sync())
+	m.Put(types.Bytes("b"), types.Bytes("l0-new"))
+	m.Delete(types.Bytes("e"))
+	m.Delete(types.Bytes("f"))
+	assert.NoError(t, m.Sync())
+
+	// memtables from the oldest
+	m.Put(types.Bytes("g"), types.Bytes("mem-old"))
+	m.Put(types.Bytes("h"), types.Bytes("mem-old"))
+	m.freeze()
+	m.Put(types.Bytes("g"), types.Bytes("mem-new"))
+	m.Delete(types.Bytes("h"))
+	m.Put(types.Bytes("b"), types.Bytes("mem-new"))
+
+	assertGet(t, m, "g", "mem-new", true) // newest memtable first
+	assertGet(t, m, "h", "", false)       // tombstone in a memtable hides L2
+	assertGet(t, m, "b", "mem-new", true) // memtables before L0
+
+	m.Delete(types.Bytes("b"))
+	assertGet(t, m, "b", "", false) // tombstone in a memtable hides L0
+
+	assertGet(t, m, "c", "l0-old", true) // L0 before levels
+	assertGet(t, m, "e", "", false)      // tombstone in the newest L0 table
+	assertGet(t, m, "f", "", false)      // tombstone in L0 hides L2
+
+	assertGet(t, m, "a", "l1", true) // first level holding the key
+	assertGet(t, m, "d", "", false)  // tombstone in L1 hides L2
+	assertGet(t, m, "n", "l2", true) // in the gap between the tables of L1
+	assertGet(t, m, "p", "l1", true)
+	assertGet(t, m, "r", "", false) // within a L1 table range, absent everywhere
+	assertGet(t, m, "z", "", false) // past every table
+}
+
+func assertMultiGet(t *testing.T, m *lsm, keys []string, want []string, wantFound []bool) {
+	t.Helper()
+	req := make([]types.Bytes, 0, len(keys))
+	for _, key := range keys {
+		req = append(req, types.Bytes(key))
+	}
+
+	vals, found, err := m.MultiGet(req)
+	assert.NoError(t, err)
+	assert.Equal(t, wantFound, found)
+	for i := range keys {
+		if wantFound[i] {
+			assert.Equal(t, want[i], string(vals[i]), "key %s", keys[i])
+		} else {
+			assert.Nil(t, vals[i], "key %s", keys[i])
+		}
+		// MultiGet agrees with Get
+		assertGet(t, m, keys[i], want[i], wantFound[i])
+	}
+}
+
+func TestMultiGet(t *testing.T) {
+	for _, parallelism := range []int{1, 4} {
+		t.Run(fmt.Sprintf("parallelism=%d", parallelism), func(t *testing.T) {
+			m := newTestLSM(t, MultiGetParallelism(parallelism))
+
+			addLevel(m,
+				addTable(t, m, "a", "l1", "c", "l1", "d", ""),
+				addTable(t, m, "p", "l1", "s", "l1"),
+			)
+			addLevel(m,
+				addTable(t, m, "a", "l2", "d", "l2", "f", "l2", "h", "l2", "n", "l2"),
+			)
+
+			m.Put(types.Bytes("b"), types.Bytes("l0-old"))
+			m.Put(types.Bytes("c"), types.Bytes("l0-old"))
+			m.Put(types.Bytes("e"), types.Bytes("l0-old"))
+			m.Put(types.Bytes("g"), types.Bytes("l0-old"))
+			assert.NoError(t, m.Sync())
+			m.Put(types.Bytes("b"), types.Bytes("l0-new"))
+			m.Delete(types.Bytes("e"))
+			m.Delete(types.Bytes("f"))
+			assert.NoError(t, m.Sync())
+
+			m.Put(types.Bytes("g"), types.Bytes("mem"))
+			m.Delete(types.Bytes("h"))
+
+			keys := []string{"z", "b", "h", "a", "e", "g", "b", "n", "f", "c", "d", "r", "a"}
+			want := []string{"", "l0-new", "", "l1", "", "mem", "l0-new", "l2", "", "l0-old", "", "", "l1"}
+			wantFound := []bool{false, true, false, true, false, true, true, true, false, true, false, false, true}
+			assertMultiGet(t, m, keys, want, wantFound)
+
+			assertMultiGet(t, m, []string{}, []string{}, []bool{})
+			assertMultiGet(t, m, []string{"x", "y", "x"}, []string{"", "", ""}, []bool{false, false, false})
+		})
+	}
+}
+
+func TestMultiGetSkipsOlderL0Tables(t *testing.T) {
+	m := newTestLSM(t, MultiGetParallelism(1))
+
+	m.Put(types.Bytes("a"), types.Bytes("old"))
+	m.Put(types.Bytes("b"), types.Bytes("old"))
+	assert.NoError(t, m.Sync())
+	m.Put(types.Bytes("a"), types.Bytes("new"))
+	m.Put(types.Bytes("b"), types.Bytes("new"))
+	assert.NoError(t, m.Sync())
+
+	// reading the oldest table would fail, keys found in the newest are not looked up there
+	oldest := m.l0SsTables[len(m.l0SsTables)-1]
+	assert.NoError(t, os.Remove(m.tablePath(oldest.Id())))
+
+	assertMultiGet(t, m, []string{"b", "a", "b"}, []string{"new", "new", "new"}, []bool{true, true, true})
+}
+
+func TestMultiGetRowCache(t *testing.T) {
+	m := newTestLSM(t, RowCacheSize(1<<20))
+
+	addLevel(m, addTable(t, m, "a", "l1", "c", "l1"))
+	m.Put(types.Bytes("b"), types.Bytes("l0"))
+	m.Delete(types.Bytes("c"))
+	assert.NoError(t, m.Sync())
+
+	keys := []string{"a", "b", "c", "d"}
+	want := []string{"l1", "l0", "", ""}
+	wantFound := []bool{true, true, false, false}
+
+	vals, found, err := m.MultiGet([]types.Bytes{types.Bytes("a"), types.Bytes("b"), types.Bytes("c"), types.Bytes("d")})
+	assert.NoError(t, err)
+	assert.Equal(t, wantFound, found)
+	assert.Equal(t, types.Bytes("l1"), vals[0])
+	assert.Equal(t, types.Bytes("l0"), vals[1])
+
+	// a, b and the tombstone of c are cached
+	rows := m.Stats().RowCache
+	assert.Equal(t, uint64(0), rows.Hits)
+	assert.Equal(t, uint64(3), rows.Inserts)
+
+	assertMultiGet(t, m, keys, want, wantFound)
+	rows = m.Stats().RowCache
+	assert.Equal(t, uint64(3), rows.Inserts)
+	// MultiGet then Get on a, b and c
+	assert.Equal(t, uint64(6), rows.Hits)
+}
+
+func TestSyncPartitionedIndex(t *testing.T) {
+	m := newTestLSM(t, IndexPartitionSize(128))
+
+	for i := range 2000 {
+		m.Put(types.Bytes(fmt.Sprintf("k%05d", i)), types.Bytes(fmt.Sprintf("v%05d", i)))
+	}
+	assert.NoError(t, m.Sync())
+	assert.Greater(t, m.l0SsTables[0].Properties().IndexPartitions, uint32(1))
+
+	for i := range 2000 {
+		assertGet(t, m, fmt.Sprintf("k%05d", i), fmt.Sprintf("v%05d", i), true)
+	}
+
+	// the top level is pinned, each lookup reads its partition to find the block and its handle
+	index := m.Stats().BlockCache.Categories["index"]
+	assert.Equal(t, uint64(2*2000), index.Hits+index.Misses)
+}
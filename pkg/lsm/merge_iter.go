@@ -30,17 +30,19 @@ func SelectMemTableItersInRange(tables []memtable.MemTable, lower types.Bound[ty
 	return iters
 }
 
-func SelectSstItersInRange(ssTables []sst.SortedTable, lower types.Bound[types.Bytes], upper types.Bound[types.Bytes]) []types.Iterator {
+func SelectSstItersInRange(ssTables []sst.SortedTable, lower types.Bound[types.Bytes], upper types.Bound[types.Bytes], options ...sst.ReadOption) []types.Iterator {
 	iters := make([]types.Iterator, 0, len(ssTables))
 
 	for _, t := range ssTables {
 		if t.OverlapKeyRange(lower, upper) {
-			sstIter, err := t.Scan()
+			sstIter, err := t.Scan(options...)
 			if err != nil {
 				panic(fmt.Sprintf("sorted table prematurely closed: %d", t.Id()))
 			}
-			if err := sstIter.SeekToKey(lower.Data()); err != nil {
-				panic("expected sstable to include this key range, got err instead: " + err.Error())
+			if !lower.IsUnbounded() {
+				if err := sstIter.SeekToKey(lower.Data()); err != nil {
+					panic("expected sstable to include this key range, got err instead: " + err.Error())
+				}
 			}
 			if sstIter.HasNext() {
 				iters = append(iters, sstIter)
@@ -51,7 +53,7 @@ func SelectSstItersInRange(ssTables []sst.SortedTable, lower types.Bound[types.B
 	return iters
 }
 
-func SelectLeveledSstInRange(ssTables [][]sst.SortedTable, lower types.Bound[types.Bytes], upper types.Bound[types.Bytes]) []types.Iterator {
+func SelectLeveledSstInRange(ssTables [][]sst.SortedTable, lower types.Bound[types.Bytes], upper types.Bound[types.Bytes], options ...sst.ReadOption) []types.Iterator {
 	iters := make([]types.Iterator, 0, len(ssTables))
 	for _, tables := range ssTables {
 		levelIters := make([]sst.SortedTable, 0, len(tables))
@@ -60,8 +62,10 @@ func SelectLeveledSstInRange(ssTables [][]sst.SortedTable, lower types.Bound[typ
 				levelIters = append(levelIters, table)
 			}
 		}
-		cc := concat.NewConcatIter(levelIters)
-		cc.SeekToKey(lower.Data())
+		cc := concat.NewConcatIter(levelIters, options...)
+		if !lower.IsUnbounded() {
+			cc.SeekToKey(lower.Data())
+		}
 		iters = append(iters, cc)
 	}
 	return iters
@@ -0,0 +1,200 @@
+package lsm
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/sst"
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+)
+
+// MultiGet returns the values of keys in the order of keys, found[i] is false when keys[i] is absent
+func (m *lsm) MultiGet(keys []types.Bytes) ([]types.Bytes, []bool, error) {
+	m.rw.RLock()
+	defer m.rw.RUnlock()
+
+	return m.multiGet(keys)
+}
+
+// tableLookup is a batch of sorted keys looked up in a single table,
+// pos maps them back to their position in the MultiGet request
+type tableLookup struct {
+	table *sst.SortedTable
+	keys  []types.Bytes
+	pos   []int
+	vals  []types.Bytes
+	found []bool
+	err   error
+}
+
+// multiGet resolves keys tier by tier like get, only the keys still
+// missing are looked up in the next tier
+func (m *lsm) multiGet(keys []types.Bytes) ([]types.Bytes, []bool, error) {
+	vals := make([]types.Bytes, len(keys))
+	found := make([]bool, len(keys))
+
+	// tables need their keys sorted to read every block once
+	pending := make([]int, 0, len(keys))
+	for i, key := range keys {
+		if vals[i], found[i] = m.getFromMemtables(key); !found[i] {
+			pending = append(pending, i)
+		}
+	}
+	sort.SliceStable(pending, func(a, b int) bool {
+		return types.BytesComparator(keys[pending[a]], keys[pending[b]]) < 0
+	})
+
+	if len(pending) > 0 && len(m.l0SsTables) > 0 {
+		var err error
+		if pending, err = m.multiGetL0(keys, pending, vals, found); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// tables of a level do not overlap, pending keys are split between them
+	for _, levelIds := range m.sstLevels {
+		if len(pending) == 0 {
+			break
+		}
+
+		byTable := make(map[*sst.SortedTable][]int)
+		tables := make([]*sst.SortedTable, 0)
+		for _, pos := range pending {
+			table, ok := m.levelTable(levelIds, keys[pos])
+			if !ok {
+				continue
+			}
+			if _, ok := byTable[table]; !ok {
+				tables = append(tables, table)
+			}
+			byTable[table] = append(byTable[table], pos)
+		}
+
+		lookups := make([]*tableLookup, 0, len(tables))
+		for _, table := range tables {
+			lookups = append(lookups, newTableLookup(table, keys, byTable[table]))
+		}
+		if err := m.runLookups(lookups); err != nil {
+			return nil, nil, err
+		}
+		for _, l := range lookups {
+			l.collect(vals, found)
+		}
+		pending = stillPending(pending, found)
+	}
+
+	// tombstones stop the lookup of their key but are reported as missing
+	for i := range keys {
+		if found[i] && vals[i].Size() == 0 {
+			vals[i], found[i] = nil, false
+		}
+	}
+
+	return vals, found, nil
+}
+
+// multiGetL0 looks pending keys up in L0 tables, which overlap. Read one after
+// the other from the newest, a table only gets the keys missing from the newer
+// ones. Read concurrently, every table gets every pending key and the newest
+// table holding a key wins
+func (m *lsm) multiGetL0(keys []types.Bytes, pending []int, vals []types.Bytes, found []bool) ([]int, error) {
+	if m.opts.MultiGetParallelism <= 1 {
+		for i := range m.l0SsTables {
+			if len(pending) == 0 {
+				break
+			}
+			l := newTableLookup(&m.l0SsTables[i], keys, pending)
+			if l.run(m); l.err != nil {
+				return nil, l.err
+			}
+			l.collect(vals, found)
+			pending = stillPending(pending, found)
+		}
+		return pending, nil
+	}
+
+	lookups := make([]*tableLookup, 0, len(m.l0SsTables))
+	for i := range m.l0SsTables {
+		lookups = append(lookups, newTableLookup(&m.l0SsTables[i], keys, pending))
+	}
+	if err := m.runLookups(lookups); err != nil {
+		return nil, err
+	}
+	for _, l := range lookups {
+		l.collect(vals, found)
+	}
+	return stillPending(pending, found), nil
+}
+
+func newTableLookup(table *sst.SortedTable, keys []types.Bytes, pos []int) *tableLookup {
+	l := &tableLookup{
+		table: table,
+		keys:  make([]types.Bytes, len(pos)),
+		pos:   pos,
+	}
+	for i, p := range pos {
+		l.keys[i] = keys[p]
+	}
+	return l
+}
+
+func (l *tableLookup) run(m *lsm) {
+	l.vals, l.found, l.err = m.tableMultiGet(l.table, l.keys)
+	if l.err != nil {
+		l.err = fmt.Errorf("failed to get from table id=%d: %s", l.table.Id(), l.err)
+	}
+}
+
+// collect keeps the values found by the lookup for keys not found yet,
+// lookups have to be collected from the newest table to the oldest
+func (l *tableLookup) collect(vals []types.Bytes, found []bool) {
+	for i, p := range l.pos {
+		if l.found[i] && !found[p] {
+			vals[p] = l.vals[i]
+			found[p] = true
+		}
+	}
+}
+
+// runLookups runs the lookups, MultiGetParallelism at a time
+func (m *lsm) runLookups(lookups []*tableLookup) error {
+	if m.opts.MultiGetParallelism <= 1 || len(lookups) <= 1 {
+		for _, l := range lookups {
+			if l.run(m); l.err != nil {
+				return l.err
+			}
+		}
+		return nil
+	}
+
+	sem := make(chan struct{}, m.opts.MultiGetParallelism)
+	wg := sync.WaitGroup{}
+	for _, l := range lookups {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			l.run(m)
+		}()
+	}
+	wg.Wait()
+
+	for _, l := range lookups {
+		if l.err != nil {
+			return l.err
+		}
+	}
+	return nil
+}
+
+func stillPending(pending []int, found []bool) []int {
+	res := pending[:0]
+	for _, p := range pending {
+		if !found[p] {
+			res = append(res, p)
+		}
+	}
+	return res
+}
@@ -1,10 +1,40 @@
 package lsm
 
+import (
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/memtable"
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/sst"
+	"github.com/ttn-nguyen42/go-mini-lsm/pkg/cache"
+)
+
 type Options struct {
-	MaxTableSize   int
+	MaxTableSize   int // memory held by a memtable before it is frozen, see memtable.MemTable.Size
 	Dir            string
 	SstLevelCount  int
-	BlockCacheSize int
+	BlockCacheSize int                    // in bytes of cached blocks
+	Encryption     sst.EncryptionProvider // encrypts every table written by the tree, nil stores plain files
+
+	BlockCachePolicy cache.Policy // LRU by default, S3FIFO resists scans
+	MaxOpenFiles     int          // table files kept open at once, others are opened on demand
+	// in bytes of compressed blocks evicted from the block cache, kept in memory
+	// to avoid reading them from disk again, 0 disables the secondary cache
+	SecondaryCacheSize int
+	// in bytes of keys and values found in tables, kept so that a point lookup
+	// does not decode the block again, 0 disables the row cache
+	RowCacheSize int
+
+	BloomBitsPerKey int
+	FilterPolicy    sst.FilterPolicy    // bloom filter with BloomBitsPerKey when nil
+	PrefixExtractor sst.PrefixExtractor // also indexes key prefixes in table filters, used to skip tables on prefix scans
+
+	// splits the index of tables into partitions of about this size read through the
+	// block cache, the top level stays in memory. 0 keeps a single level index
+	IndexPartitionSize uint32
+
+	MultiGetParallelism int // tables looked up concurrently by MultiGet, 1 reads them one after the other
+
+	// creates the memtables, skip lists by default. memtable.NewVector suits bulk loads
+	// of unsorted keys, memtable.NewHashSkipList point lookups of keys sharing prefixes
+	MemTableFactory memtable.Factory
 }
 
 type Option func(*Options)
@@ -14,7 +44,15 @@ func getOptions(opts ...Option) *Options {
 		MaxTableSize:   256 * 1024 * 1024,
 		Dir:            "/tmp/mini_lsm",
 		SstLevelCount:  3,
-		BlockCacheSize: 1 << 20, //4GB
+		BlockCacheSize: 64 << 20, // 64MB
+		MaxOpenFiles:   1000,
+
+		BloomBitsPerKey: 10,
+		PrefixExtractor: nil,
+
+		MultiGetParallelism: 1,
+
+		MemTableFactory: memtable.New,
 	}
 
 	for _, opt := range opts {
@@ -47,3 +85,94 @@ func BlockCacheSize(size int) Option {
 		o.BlockCacheSize = size
 	}
 }
+
+func BlockCachePolicy(p cache.Policy) Option {
+	return func(o *Options) {
+		o.BlockCachePolicy = p
+	}
+}
+
+func SecondaryCacheSize(size int) Option {
+	return func(o *Options) {
+		o.SecondaryCacheSize = size
+	}
+}
+
+func RowCacheSize(size int) Option {
+	return func(o *Options) {
+		o.RowCacheSize = size
+	}
+}
+
+func MaxOpenFiles(n int) Option {
+	return func(o *Options) {
+		o.MaxOpenFiles = n
+	}
+}
+
+func Encryption(enc sst.EncryptionProvider) Option {
+	return func(o *Options) {
+		o.Encryption = enc
+	}
+}
+
+func BloomBitsPerKey(bitsPerKey int) Option {
+	return func(o *Options) {
+		o.BloomBitsPerKey = bitsPerKey
+	}
+}
+
+func FilterPolicy(p sst.FilterPolicy) Option {
+	return func(o *Options) {
+		o.FilterPolicy = p
+	}
+}
+
+func PrefixExtractor(pe sst.PrefixExtractor) Option {
+	return func(o *Options) {
+		o.PrefixExtractor = pe
+	}
+}
+
+func IndexPartitionSize(size uint32) Option {
+	return func(o *Options) {
+		o.IndexPartitionSize = size
+	}
+}
+
+func MultiGetParallelism(n int) Option {
+	return func(o *Options) {
+		o.MultiGetParallelism = n
+	}
+}
+
+func MemTableFactory(f memtable.Factory) Option {
+	return func(o *Options) {
+		o.MemTableFactory = f
+	}
+}
+
+type ReadOptions struct {
+	FillCache bool // blocks read by the scan are added to the block cache
+}
+
+type ReadOption func(*ReadOptions)
+
+func getReadOptions(opts ...ReadOption) *ReadOptions {
+	o := &ReadOptions{
+		FillCache: true,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// FillCache set to false keeps large scans from evicting the cached blocks of point lookups
+func FillCache(fill bool) ReadOption {
+	return func(o *ReadOptions) {
+		o.FillCache = fill
+	}
+}
@@ -0,0 +1,406 @@
+package skiplist
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ttn-nguyen42/go-mini-lsm/internal/types"
+	"github.com/ttn-nguyen42/go-mini-lsm/pkg/arena"
+)
+
+// concurrentNode is never unlinked once inserted, a deleted node only loses its value
+type concurrentNode[K, V any] struct {
+	key   K
+	value atomic.Pointer[V] // nil once deleted
+	next  []atomic.Pointer[concurrentNode[K, V]]
+}
+
+// nodeAllocator allocates nodes from an arena, or from the heap without one
+type nodeAllocator[K, V any] struct {
+	nodes  *arena.Allocator[concurrentNode[K, V]]
+	levels *arena.Allocator[atomic.Pointer[concurrentNode[K, V]]]
+	values *arena.Allocator[V]
+}
+
+func newNodeAllocator[K, V any](a *arena.Arena) nodeAllocator[K, V] {
+	if a == nil {
+		return nodeAllocator[K, V]{}
+	}
+	return nodeAllocator[K, V]{
+		nodes:  arena.NewAllocator[concurrentNode[K, V]](a),
+		levels: arena.NewAllocator[atomic.Pointer[concurrentNode[K, V]]](a),
+		values: arena.NewAllocator[V](a),
+	}
+}
+
+func (a nodeAllocator[K, V]) node(key K, height int) *concurrentNode[K, V] {
+	if a.nodes == nil {
+		return &concurrentNode[K, V]{
+			key:  key,
+			next: make([]atomic.Pointer[concurrentNode[K, V]], height),
+		}
+	}
+
+	n := a.nodes.New()
+	n.key = key
+	n.next = a.levels.Alloc(height)
+	return n
+}
+
+func (a nodeAllocator[K, V]) value(value V) *V {
+	if a.values == nil {
+		return &value
+	}
+
+	v := a.values.New()
+	*v = value
+	return v
+}
+
+// concurrentSkipList links nodes with compare-and-swap instead of locking,
+// writers and readers never wait on each other. Nodes are linked on level 0
+// first, a node found on level 0 is in the list even if its upper levels are
+// still being linked
+type concurrentSkipList[K, V any] struct {
+	opts *Options
+
+	head    *concurrentNode[K, V]
+	curSize atomic.Int64
+	cmp     types.Comparator[K]
+	alloc   nodeAllocator[K, V]
+}
+
+// NewConcurrent creates a skip list safe for concurrent writers and readers without locking.
+// Deleted keys keep their node, the list only grows until it is dropped
+func NewConcurrent[K, V any](cmp types.Comparator[K], options ...Option) (SkipList[K, V], error) {
+	opts, err := getOptions(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &concurrentSkipList[K, V]{
+		opts:  opts,
+		cmp:   cmp,
+		alloc: newNodeAllocator[K, V](opts.Arena),
+	}
+	var empty K
+	s.head = s.alloc.node(empty, s.height())
+	return s, nil
+}
+
+func (s *concurrentSkipList[K, V]) IsEmpty() bool {
+	return s.Size() == 0
+}
+
+func (s *concurrentSkipList[K, V]) Size() int {
+	return int(s.curSize.Load())
+}
+
+func (s *concurrentSkipList[K, V]) Get(key K) (V, bool) {
+	var empty V
+
+	n := s.find(key)
+	if n == nil {
+		return empty, false
+	}
+	val := n.value.Load()
+	if val == nil {
+		return empty, false
+	}
+	return *val, true
+}
+
+func (s *concurrentSkipList[K, V]) Delete(key K) bool {
+	n := s.find(key)
+	if n == nil {
+		return false
+	}
+
+	for {
+		val := n.value.Load()
+		if val == nil {
+			return false
+		}
+		if n.value.CompareAndSwap(val, nil) {
+			s.curSize.Add(-1)
+			return true
+		}
+	}
+}
+
+func (s *concurrentSkipList[K, V]) Put(key K, value V) (old V, replaced bool) {
+	var preds, succs [maxConcurrentHeight]*concurrentNode[K, V]
+
+	var n *concurrentNode[K, V]
+	for {
+		if found := s.findSplice(key, preds[:], succs[:]); found != nil {
+			prev := found.value.Swap(s.alloc.value(value))
+			if prev == nil {
+				s.curSize.Add(1)
+				return old, false
+			}
+			return *prev, true
+		}
+
+		if n == nil {
+			n = s.alloc.node(key, s.randomHeight())
+			n.value.Store(s.alloc.value(value))
+		}
+		n.next[0].Store(succs[0])
+		if preds[0].next[0].CompareAndSwap(succs[0], n) {
+			break
+		}
+		// another writer linked a node in between, look again
+	}
+	s.curSize.Add(1)
+
+	for lvl := 1; lvl < len(n.next); lvl += 1 {
+		for {
+			n.next[lvl].Store(succs[lvl])
+			if preds[lvl].next[lvl].CompareAndSwap(succs[lvl], n) {
+				break
+			}
+			preds[lvl], succs[lvl] = s.spliceAt(preds[lvl], key, lvl)
+		}
+	}
+
+	return old, false
+}
+
+// maxConcurrentHeight bounds the levels of a node so that splices fit on the stack
+const maxConcurrentHeight = 64
+
+func (s *concurrentSkipList[K, V]) height() int {
+	return min(s.opts.MaxLevel+1, maxConcurrentHeight)
+}
+
+// randomHeight returns the number of levels of a new node, each level is half as likely as the one below
+func (s *concurrentSkipList[K, V]) randomHeight() int {
+	h := 1
+	for h < s.height() && rand.Int31()%2 != 0 {
+		h += 1
+	}
+	return h
+}
+
+// findSplice fills the nodes between which key goes on every level,
+// or returns the node of key when it is already in the list
+func (s *concurrentSkipList[K, V]) findSplice(key K, preds, succs []*concurrentNode[K, V]) *concurrentNode[K, V] {
+	pred := s.head
+	for lvl := s.height() - 1; lvl >= 0; lvl -= 1 {
+		var succ *concurrentNode[K, V]
+		pred, succ = s.spliceAt(pred, key, lvl)
+		if succ != nil && s.cmp(succ.key, key) == 0 {
+			return succ
+		}
+		preds[lvl], succs[lvl] = pred, succ
+	}
+	return nil
+}
+
+// spliceAt walks level from start to the last node before key and the node that follows it
+func (s *concurrentSkipList[K, V]) spliceAt(start *concurrentNode[K, V], key K, level int) (pred, succ *concurrentNode[K, V]) {
+	pred = start
+	for {
+		succ = pred.next[level].Load()
+		if succ == nil || s.cmp(succ.key, key) >= 0 {
+			return pred, succ
+		}
+		pred = succ
+	}
+}
+
+// find returns the node of key, deleted or not
+func (s *concurrentSkipList[K, V]) find(key K) *concurrentNode[K, V] {
+	pred := s.head
+	for lvl := s.height() - 1; lvl >= 0; lvl -= 1 {
+		var succ *concurrentNode[K, V]
+		pred, succ = s.spliceAt(pred, key, lvl)
+		if succ != nil && s.cmp(succ.key, key) == 0 {
+			return succ
+		}
+	}
+	return nil
+}
+
+// seek returns the first node that is not before lower
+func (s *concurrentSkipList[K, V]) seek(lower types.Bound[K]) *concurrentNode[K, V] {
+	pred := s.head
+	for lvl := s.height() - 1; lvl >= 0; lvl -= 1 {
+		for {
+			succ := pred.next[lvl].Load()
+			if succ == nil || !lower.IsBefore(succ.key, s.cmp) {
+				break
+			}
+			pred = succ
+		}
+	}
+	return pred.next[0].Load()
+}
+
+// last returns the last node not after upper, or the last node without upper.
+// Returns the head when there is none
+func (s *concurrentSkipList[K, V]) last(upper *types.Bound[K]) *concurrentNode[K, V] {
+	pred := s.head
+	for lvl := s.height() - 1; lvl >= 0; lvl -= 1 {
+		for {
+			succ := pred.next[lvl].Load()
+			if succ == nil || (upper != nil && upper.IsAfter(succ.key, s.cmp)) {
+				break
+			}
+			pred = succ
+		}
+	}
+	return pred
+}
+
+// lastBefore returns the last node before key, the head when there is none
+func (s *concurrentSkipList[K, V]) lastBefore(key K) *concurrentNode[K, V] {
+	pred := s.head
+	for lvl := s.height() - 1; lvl >= 0; lvl -= 1 {
+		pred, _ = s.spliceAt(pred, key, lvl)
+	}
+	return pred
+}
+
+func (s *concurrentSkipList[K, V]) Scan(lower types.Bound[K], upper types.Bound[K]) Iterator[K, V] {
+	it := &concurrentIter[K, V]{
+		list:  s,
+		lower: &lower,
+		upper: &upper,
+	}
+	it.moveTo(s.seek(lower))
+	return it
+}
+
+func (s *concurrentSkipList[K, V]) Iter() Iterator[K, V] {
+	it := &concurrentIter[K, V]{list: s}
+	it.moveTo(s.head.next[0].Load())
+	return it
+}
+
+func (s *concurrentSkipList[K, V]) String() string {
+	sb := strings.Builder{}
+
+	for lvl := s.height() - 1; lvl >= 0; lvl -= 1 {
+		sb.WriteString(fmt.Sprintf("Level %d: HEAD -> ", lvl))
+
+		for n := s.head.next[lvl].Load(); n != nil; n = n.next[lvl].Load() {
+			sb.WriteString(fmt.Sprintf("%v -> ", n.key))
+		}
+
+		sb.WriteString("TAIL \n")
+	}
+
+	return sb.String()
+}
+
+// concurrentIter sees the keys inserted after it was created when it has not passed them yet.
+// Nodes have no link to the previous one, moving backward searches the list again
+type concurrentIter[K, V any] struct {
+	list  *concurrentSkipList[K, V]
+	cur   *concurrentNode[K, V]
+	val   *V // value of cur when the iterator moved to it
+	lower *types.Bound[K]
+	upper *types.Bound[K]
+	done  bool
+}
+
+// moveTo moves to the first node from n that is not deleted
+func (i *concurrentIter[K, V]) moveTo(n *concurrentNode[K, V]) {
+	for n != nil {
+		if i.upper != nil && i.upper.IsAfter(n.key, i.list.cmp) {
+			n = nil
+			break
+		}
+		if val := n.value.Load(); val != nil {
+			i.val = val
+			break
+		}
+		n = n.next[0].Load()
+	}
+	i.cur = n
+}
+
+// moveBack moves to the last node from n backward that is not deleted
+func (i *concurrentIter[K, V]) moveBack(n *concurrentNode[K, V]) {
+	for n != i.list.head {
+		if i.lower != nil && i.lower.IsBefore(n.key, i.list.cmp) {
+			break
+		}
+		if val := n.value.Load(); val != nil {
+			i.val = val
+			i.cur = n
+			return
+		}
+		n = i.list.lastBefore(n.key)
+	}
+	i.cur = nil
+}
+
+func (i *concurrentIter[K, V]) HasNext() bool {
+	return !i.done && i.cur != nil
+}
+
+func (i *concurrentIter[K, V]) Key() K {
+	if !i.HasNext() {
+		panic("iterator has ended")
+	}
+	return i.cur.key
+}
+
+func (i *concurrentIter[K, V]) Value() V {
+	if !i.HasNext() {
+		panic("iterator has ended")
+	}
+	return *i.val
+}
+
+func (i *concurrentIter[K, V]) Next() error {
+	if !i.HasNext() {
+		i.done = true
+		return ErrIterEnded
+	}
+
+	i.moveTo(i.cur.next[0].Load())
+	return nil
+}
+
+func (i *concurrentIter[K, V]) Prev() error {
+	if !i.HasNext() {
+		i.done = true
+		return ErrIterEnded
+	}
+
+	i.moveBack(i.list.lastBefore(i.cur.key))
+	return nil
+}
+
+func (i *concurrentIter[K, V]) SeekToLast() {
+	i.done = false
+	i.moveBack(i.list.last(i.upper))
+}
+
+func (i *concurrentIter[K, V]) SeekToKey(key K) {
+	i.done = false
+	lower := types.Include(key)
+	if i.lower != nil && i.lower.IsBefore(key, i.list.cmp) {
+		lower = *i.lower
+	}
+	i.moveTo(i.list.seek(lower))
+}
+
+func (i *concurrentIter[K, V]) SeekForPrev(key K) {
+	i.done = false
+	upper := types.Include(key)
+	if i.upper != nil && i.upper.IsAfter(key, i.list.cmp) {
+		upper = *i.upper
+	}
+	i.moveBack(i.list.last(&upper))
+}
+
+func (i *concurrentIter[K, V]) Close() {
+	i.done = true
+}
@@ -11,6 +11,13 @@ var ErrIterEnded = fmt.Errorf("iterator ended")
 type Iterator[K, V any] interface {
 	HasNext() bool
 	Next() error
+	// Prev moves to the previous key, before the first one the iterator is on no key
+	Prev() error
+	SeekToLast()
+	// SeekToKey moves to the first key >= key
+	SeekToKey(key K)
+	// SeekForPrev moves to the last key <= key
+	SeekForPrev(key K)
 	Key() K
 	Value() V
 	Close()
@@ -63,37 +70,94 @@ func moveToClosest[K, V any](list *skipListImpl[K, V], lower types.Bound[K], upp
 	return iter.getCell(0).next
 }
 
+// lastNotAfter returns the last node not after upper, the head when there is none
+func (s *skipListImpl[K, V]) lastNotAfter(upper types.Bound[K]) *node[K, V] {
+	iter := s.head
+	cmp := types.Comparator[K](s.cmp)
+	for lvl := s.opts.MaxLevel; lvl >= 0; lvl -= 1 {
+		cell := iter.getCell(lvl)
+		for cell != nil && cell.next != s.tail && !upper.IsAfter(cell.next.key, cmp) {
+			iter = cell.next
+			cell = iter.getCell(lvl)
+		}
+	}
+	return iter
+}
+
 func (l *listIter[K, V]) HasNext() bool {
 	if l.done {
 		return false
 	}
-	return l.cur != nil && l.cur != l.list.tail && types.IsWithinBoundary(l.lower, l.upper, l.cur.key, l.cmp)
+	return l.cur != nil && l.cur != l.list.tail && l.cur != l.list.head && types.IsWithinBoundary(l.lower, l.upper, l.cur.key, l.cmp)
 }
 
 func (l *listIter[K, V]) Key() K {
-	if l.done || l.cur == nil || l.cur == l.list.tail || !types.IsWithinBoundary(l.lower, l.upper, l.cur.key, l.cmp) {
+	if !l.HasNext() {
 		panic("iterator has ended")
 	}
 	return l.cur.key
 }
 
 func (l *listIter[K, V]) Next() error {
-	if l.done {
+	if !l.HasNext() {
+		l.end()
 		return ErrIterEnded
 	}
 
-	if l.cur == nil || l.cur == l.list.tail || !types.IsWithinBoundary(l.lower, l.upper, l.cur.key, l.cmp) {
-		l.done = true
-		l.list.lock.RUnlock()
+	l.cur = l.cur.getCell(0).next
+	return nil
+}
+
+func (l *listIter[K, V]) Prev() error {
+	if !l.HasNext() {
+		l.end()
 		return ErrIterEnded
 	}
 
-	l.cur = l.cur.getCell(0).next
+	l.cur = l.cur.getCell(0).prev
 	return nil
 }
 
+// end releases the list, seeking takes it again
+func (l *listIter[K, V]) end() {
+	if !l.done {
+		l.done = true
+		l.list.lock.RUnlock()
+	}
+}
+
+func (l *listIter[K, V]) resume() {
+	if l.done {
+		l.list.lock.RLock()
+		l.done = false
+	}
+}
+
+func (l *listIter[K, V]) SeekToLast() {
+	l.resume()
+	l.cur = l.list.lastNotAfter(l.upper)
+}
+
+func (l *listIter[K, V]) SeekToKey(key K) {
+	l.resume()
+	lower := types.Include(key)
+	if l.lower.IsBefore(key, l.cmp) {
+		lower = l.lower
+	}
+	l.cur = moveToClosest(l.list, lower, l.upper)
+}
+
+func (l *listIter[K, V]) SeekForPrev(key K) {
+	l.resume()
+	upper := types.Include(key)
+	if l.upper.IsAfter(key, l.cmp) {
+		upper = l.upper
+	}
+	l.cur = l.list.lastNotAfter(upper)
+}
+
 func (l *listIter[K, V]) Value() V {
-	if l.done || l.cur == nil || l.cur == l.list.tail || !types.IsWithinBoundary(l.lower, l.upper, l.cur.key, l.cmp) {
+	if !l.HasNext() {
 		panic("iterator has ended")
 	}
 
@@ -101,10 +165,7 @@ func (l *listIter[K, V]) Value() V {
 }
 
 func (l *listIter[K, V]) Close() {
-	if !l.done {
-		l.done = true
-		l.list.lock.RUnlock()
-	}
+	l.end()
 }
 
 func (s *skipListImpl[K, V]) Scan(lower types.Bound[K], upper types.Bound[K]) Iterator[K, V] {
@@ -1,9 +1,14 @@
 package skiplist
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/ttn-nguyen42/go-mini-lsm/pkg/arena"
+)
 
 type Options struct {
 	MaxLevel int
+	Arena    *arena.Arena // nodes of the concurrent skip list are allocated from it when set
 }
 
 type Option func(*Options)
@@ -14,6 +19,13 @@ func WithMaxLevel(level int) Option {
 	}
 }
 
+// WithArena allocates the nodes, their levels and values from a, only used by NewConcurrent
+func WithArena(a *arena.Arena) Option {
+	return func(o *Options) {
+		o.Arena = a
+	}
+}
+
 func getOptions(opts ...Option) (*Options, error) {
 	options := &Options{
 		MaxLevel: 20,
@@ -15,7 +15,8 @@ type SkipList[K, V any] interface {
 	Get(key K) (V, bool)
 	Delete(key K) bool
 	Size() int
-	Put(key K, value V)
+	// Put returns the value replaced by value, if key was in the list
+	Put(key K, value V) (old V, replaced bool)
 	Scan(lower types.Bound[K], upper types.Bound[K]) Iterator[K, V]
 	Iter() Iterator[K, V]
 	String() string
@@ -89,11 +90,11 @@ func (s *skipListImpl[K, V]) Delete(key K) bool {
 	return s.delete(key)
 }
 
-func (s *skipListImpl[K, V]) Put(key K, value V) {
+func (s *skipListImpl[K, V]) Put(key K, value V) (old V, replaced bool) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	s.put(key, value)
+	return s.put(key, value)
 }
 
 func (s *skipListImpl[K, V]) isEmpty() bool {
@@ -149,15 +150,16 @@ func (s *skipListImpl[K, V]) delete(key K) bool {
 	return false
 }
 
-func (s *skipListImpl[K, V]) put(key K, value V) {
+func (s *skipListImpl[K, V]) put(key K, value V) (old V, replaced bool) {
 	iter := s.head
 	for lvl := s.opts.MaxLevel; lvl >= 0; lvl -= 1 {
 		iter = s.getClosest(iter, key, lvl)
 	}
 
 	if s.cmp(iter.key, key) == 0 {
+		old = iter.value
 		iter.value = value
-		return
+		return old, true
 	}
 
 	n := newNode[K, V](s.opts.MaxLevel)
@@ -188,6 +190,7 @@ func (s *skipListImpl[K, V]) put(key K, value V) {
 	}
 
 	s.curSize += 1
+	return old, false
 }
 
 func (s *skipListImpl[K, V]) getClosest(start *node[K, V], key K, level int) *node[K, V] {
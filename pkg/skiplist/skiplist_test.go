@@ -2,6 +2,8 @@ package skiplist_test
 
 import (
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -23,7 +25,11 @@ func TestPut(t *testing.T) {
 	assert.NoError(t, err)
 
 	sl.Put(1, 0)
-	sl.Put(2, 3)
+	_, replaced := sl.Put(2, 3)
+	assert.False(t, replaced)
+	old, replaced := sl.Put(2, 3)
+	assert.True(t, replaced)
+	assert.Equal(t, 3, old)
 
 	v, ok := sl.Get(1)
 	assert.True(t, ok)
@@ -205,3 +211,216 @@ func TestScan_ExclusiveBounds(t *testing.T) {
 	}
 	assert.Empty(t, keys)
 }
+
+func TestConcurrentPutDelete(t *testing.T) {
+	sl, err := skiplist.NewConcurrent[int, int](intCmp)
+	assert.NoError(t, err)
+
+	sl.Put(1, 0)
+	sl.Put(2, 3)
+	old, replaced := sl.Put(2, 4)
+	assert.True(t, replaced)
+	assert.Equal(t, 3, old)
+	assert.Equal(t, 2, sl.Size())
+
+	v, ok := sl.Get(2)
+	assert.True(t, ok)
+	assert.Equal(t, 4, v)
+
+	assert.True(t, sl.Delete(1))
+	assert.False(t, sl.Delete(1))
+	assert.False(t, sl.Delete(10))
+	_, ok = sl.Get(1)
+	assert.False(t, ok)
+	assert.Equal(t, 1, sl.Size())
+
+	// a deleted key can be put again, it replaces nothing
+	_, replaced = sl.Put(1, 5)
+	assert.False(t, replaced)
+	v, ok = sl.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, 5, v)
+	assert.Equal(t, 2, sl.Size())
+}
+
+func TestConcurrentScan(t *testing.T) {
+	sl, err := skiplist.NewConcurrent[int, int](intCmp)
+	assert.NoError(t, err)
+
+	for _, k := range []int{1, 5, 3, 10, 2, 7} {
+		sl.Put(k, k*10)
+	}
+	sl.Delete(7)
+
+	collect := func(iter skiplist.Iterator[int, int]) []int {
+		defer iter.Close()
+		var keys []int
+		for iter.HasNext() {
+			assert.Equal(t, iter.Key()*10, iter.Value())
+			keys = append(keys, iter.Key())
+			iter.Next()
+		}
+		return keys
+	}
+
+	assert.Equal(t, []int{1, 2, 3, 5, 10}, collect(sl.Iter()))
+	assert.Equal(t, []int{2, 3, 5}, collect(sl.Scan(types.Include(2), types.Include(5))))
+	assert.Equal(t, []int{3, 5}, collect(sl.Scan(types.Exclude(2), types.Exclude(10))))
+	assert.Empty(t, collect(sl.Scan(types.Exclude(5), types.Exclude(10))))
+}
+
+// TestConcurrentStress writes overlapping keys from many goroutines while others read,
+// the result must match the locked skip list fed the same keys
+func TestConcurrentStress(t *testing.T) {
+	const (
+		writers = 8
+		readers = 4
+		keys    = 2000
+	)
+
+	sl, err := skiplist.NewConcurrent[int, int](intCmp)
+	assert.NoError(t, err)
+	ref, err := skiplist.New[int, int](intCmp)
+	assert.NoError(t, err)
+
+	var writing sync.WaitGroup
+	for w := range writers {
+		writing.Add(1)
+		go func() {
+			defer writing.Done()
+			// writers share half of their keys with the next one
+			for i := range keys {
+				// the locked list keeps the zero key in its head node, keys start at 1
+				k := (w*keys/2+i*7919)%(writers*keys/2) + 1
+				sl.Put(k, k*2)
+				ref.Put(k, k*2)
+			}
+		}()
+	}
+
+	stop := make(chan struct{})
+	var reading sync.WaitGroup
+	for r := range readers {
+		reading.Add(1)
+		go func() {
+			defer reading.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				if v, ok := sl.Get(r * 13); ok {
+					assert.Equal(t, r*26, v)
+				}
+
+				prev := -1
+				iter := sl.Scan(types.Include(r*100), types.Exclude(r*100+500))
+				for iter.HasNext() {
+					assert.Greater(t, iter.Key(), prev)
+					assert.Equal(t, iter.Key()*2, iter.Value())
+					prev = iter.Key()
+					iter.Next()
+				}
+				iter.Close()
+			}
+		}()
+	}
+
+	writing.Wait()
+	close(stop)
+	reading.Wait()
+
+	assert.Equal(t, ref.Size(), sl.Size())
+
+	var got, want []int
+	iter := sl.Iter()
+	for iter.HasNext() {
+		got = append(got, iter.Key())
+		iter.Next()
+	}
+	iter.Close()
+	iter = ref.Iter()
+	for iter.HasNext() {
+		want = append(want, iter.Key())
+		iter.Next()
+	}
+	iter.Close()
+	assert.Equal(t, want, got)
+}
+
+func BenchmarkConcurrentPut(b *testing.B) {
+	for _, impl := range []struct {
+		name string
+		new  func() (skiplist.SkipList[int, int], error)
+	}{
+		{"locked", func() (skiplist.SkipList[int, int], error) { return skiplist.New[int, int](intCmp) }},
+		{"lock-free", func() (skiplist.SkipList[int, int], error) { return skiplist.NewConcurrent[int, int](intCmp) }},
+	} {
+		b.Run(impl.name, func(b *testing.B) {
+			sl, _ := impl.new()
+			var next atomic.Int64
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					k := int(next.Add(1) * 2654435761 % (1 << 30))
+					sl.Put(k, k)
+				}
+			})
+		})
+	}
+}
+
+func TestReverseIteration(t *testing.T) {
+	for name, newList := range map[string]func() (skiplist.SkipList[int, int], error){
+		"locked":    func() (skiplist.SkipList[int, int], error) { return skiplist.New[int, int](intCmp) },
+		"lock-free": func() (skiplist.SkipList[int, int], error) { return skiplist.NewConcurrent[int, int](intCmp) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			sl, err := newList()
+			assert.NoError(t, err)
+			for _, k := range []int{1, 5, 3, 10, 2, 7} {
+				sl.Put(k, k*10)
+			}
+
+			backward := func(iter skiplist.Iterator[int, int]) []int {
+				var keys []int
+				for iter.HasNext() {
+					assert.Equal(t, iter.Key()*10, iter.Value())
+					keys = append(keys, iter.Key())
+					iter.Prev()
+				}
+				return keys
+			}
+
+			iter := sl.Iter()
+			iter.SeekToLast()
+			assert.Equal(t, []int{10, 7, 5, 3, 2, 1}, backward(iter))
+			iter.Close()
+
+			// bounds hold in both directions
+			iter = sl.Scan(types.Exclude(2), types.Exclude(10))
+			iter.SeekToLast()
+			assert.Equal(t, []int{7, 5, 3}, backward(iter))
+
+			iter.SeekForPrev(6)
+			assert.Equal(t, []int{5, 3}, backward(iter))
+			iter.SeekForPrev(100)
+			assert.Equal(t, 7, iter.Key())
+			iter.SeekForPrev(2)
+			assert.False(t, iter.HasNext())
+
+			// change of direction
+			iter.SeekToKey(4)
+			assert.Equal(t, 5, iter.Key())
+			iter.Next()
+			assert.Equal(t, 7, iter.Key())
+			iter.Prev()
+			iter.Prev()
+			assert.Equal(t, 3, iter.Key())
+			iter.SeekToKey(0)
+			assert.Equal(t, 3, iter.Key())
+			iter.Close()
+		})
+	}
+}